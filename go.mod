@@ -2,7 +2,10 @@ module github.com/mgazza/SmaliSwagger
 
 go 1.23.2
 
-require github.com/go-openapi/spec v0.21.0
+require (
+	github.com/go-openapi/spec v0.21.0
+	gopkg.in/yaml.v3 v3.0.1
+)
 
 require (
 	github.com/go-openapi/jsonpointer v0.21.0 // indirect
@@ -10,5 +13,4 @@ require (
 	github.com/go-openapi/swag v0.23.0 // indirect
 	github.com/josharian/intern v1.0.0 // indirect
 	github.com/mailru/easyjson v0.7.7 // indirect
-	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
@@ -1,4 +1,4 @@
-package SmaliSwagger
+package main
 
 import (
 	"encoding/json"
@@ -27,6 +27,11 @@ func main() {
 	// Define CLI flags
 	pathFlag := flag.String("path", "", "Directory containing Smali files (default: current working directory)")
 	outputFlag := flag.String("output", "swagger.json", "Path to the output Swagger JSON file")
+	typesFlag := flag.String("types", "", "YAML or JSON file mapping smali types to fixed schemas")
+	mappingFlag := flag.String("mapping", "", "ProGuard/R8 mapping.txt to deobfuscate class, field and method names")
+	titleFlag := flag.String("title", "", "Spec title (default: the app label from AndroidManifest.xml)")
+	versionFlag := flag.String("version", "", "Spec version (default: versionName from apktool.yml)")
+	showSecretsFlag := flag.Bool("show-secrets", false, "Include the constant API keys interceptors send in the spec (default: redacted)")
 
 	// Parse command-line flags
 	flag.Parse()
@@ -50,6 +55,17 @@ func main() {
 	log.Printf("Using Smali directory: %s", smaliDir)
 	log.Printf("Output file: %s", *outputFlag)
 
+	if *typesFlag != "" {
+		if err := parser.LoadTypeMappings(*typesFlag); err != nil {
+			log.Fatalf("Error loading type mappings: %v", err)
+		}
+	}
+	if *mappingFlag != "" {
+		if err := parser.LoadProguardMapping(*mappingFlag); err != nil {
+			log.Fatalf("Error loading proguard mapping: %v", err)
+		}
+	}
+
 	log.Println("Starting scanning...")
 
 	// 1) Gather all .smali in a directory
@@ -64,6 +80,23 @@ func main() {
 	if err != nil {
 		log.Fatalf("Error scanning smali: %v", err)
 	}
+	if err = parser.LoadResources(smaliDir); err != nil {
+		log.Fatalf("Error loading string resources: %v", err)
+	}
+	if err = parser.LoadAppInfo(smaliDir); err != nil {
+		log.Fatalf("Error loading app info: %v", err)
+	}
+	parser.SetInfo(*titleFlag, *versionFlag)
+	parser.ShowSecrets(*showSecretsFlag)
+	if err = parser.ScanBaseURLs(files); err != nil {
+		log.Fatalf("Error scanning base URLs: %v", err)
+	}
+	if err = parser.ScanInterceptors(files); err != nil {
+		log.Fatalf("Error scanning interceptors: %v", err)
+	}
+	if err = parser.ScanOAuth(files); err != nil {
+		log.Fatalf("Error scanning OAuth2 flows: %v", err)
+	}
 
 	// 3) Parse each smali for endpoints
 	var allEndpoints []*parser.APIEndpoint
@@ -92,10 +125,10 @@ func main() {
 		log.Fatalf("Error generating Swagger spec: %v", err)
 	}
 
-	// 5) Output swagger.json
-	fw, err := os.Create("swagger.json")
+	// 5) Output the spec
+	fw, err := os.Create(*outputFlag)
 	if err != nil {
-		log.Fatalf("Error creating swagger.json: %v", err)
+		log.Fatalf("Error creating %s: %v", *outputFlag, err)
 	}
 	defer fw.Close()
 
@@ -104,5 +137,8 @@ func main() {
 	if err = e.Encode(spec); err != nil {
 		log.Printf("Error encoding Swagger spec: %v", err)
 	}
-	log.Println("Done. Wrote swagger.json")
+	for _, d := range parser.Diagnostics() {
+		log.Printf("Diagnostic: %s", d)
+	}
+	log.Printf("Done. Wrote %s", *outputFlag)
 }
@@ -0,0 +1,261 @@
+package parser
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Regex to capture any annotation block, e.g.
+// `.annotation runtime Lretrofit2/http/GET; value = "..." .end annotation`
+var annotationBlockPattern = regexp.MustCompile(
+	`(?s)\.annotation\s+(\w+)\s+(L[^;\s]+;)(.*?)\.end annotation`)
+
+// SmaliAnnotation is a single parsed `.annotation` block.
+// Element values are kept raw (as written in the smali) and decoded on demand.
+type SmaliAnnotation struct {
+	Visibility string            // runtime, system or build
+	Type       string            // e.g. "Lretrofit2/http/GET;"
+	Elements   map[string]string // element name => raw value
+}
+
+// parseAnnotations finds every annotation block in body, in order of appearance.
+func parseAnnotations(body string) []SmaliAnnotation {
+	var annotations []SmaliAnnotation
+	for _, m := range annotationBlockPattern.FindAllStringSubmatch(body, -1) {
+		annotations = append(annotations, SmaliAnnotation{
+			Visibility: m[1],
+			Type:       m[2],
+			Elements:   parseAnnotationElements(m[3]),
+		})
+	}
+	return annotations
+}
+
+// methodAnnotations returns the annotations declared on the method itself,
+// ignoring those attached to its `.param` blocks.
+func methodAnnotations(body string) []SmaliAnnotation {
+	var b strings.Builder
+	last := 0
+	for _, block := range findParamBlocks(body) {
+		b.WriteString(body[last:block.start])
+		last = block.end
+	}
+	b.WriteString(body[last:])
+	return parseAnnotations(b.String())
+}
+
+// findAnnotation returns the first annotation of the given type.
+func findAnnotation(annotations []SmaliAnnotation, typ string) (SmaliAnnotation, bool) {
+	for _, a := range annotations {
+		if a.Type == typ {
+			return a, true
+		}
+	}
+	return SmaliAnnotation{}, false
+}
+
+// String returns the element decoded as a single string literal.
+func (a SmaliAnnotation) String(name string) (string, bool) {
+	raw, ok := a.Elements[name]
+	if !ok {
+		return "", false
+	}
+	values := splitAnnotationArray(raw)
+	if len(values) == 0 {
+		return "", true
+	}
+	return unquoteSmali(values[0]), true
+}
+
+// Strings returns the element decoded as a list of string literals.
+// A scalar value is returned as a single item list.
+func (a SmaliAnnotation) Strings(name string) []string {
+	raw, ok := a.Elements[name]
+	if !ok {
+		return nil
+	}
+	var result []string
+	for _, v := range splitAnnotationArray(raw) {
+		result = append(result, unquoteSmali(v))
+	}
+	return result
+}
+
+// Bool returns the element decoded as a boolean, false when missing.
+func (a SmaliAnnotation) Bool(name string) bool {
+	return strings.TrimSpace(a.Elements[name]) == "true"
+}
+
+// parseAnnotationElements splits the body of an annotation into `name = value` pairs.
+// Values may be string literals, arrays spanning several lines or sub annotations.
+func parseAnnotationElements(body string) map[string]string {
+	elements := map[string]string{}
+	i := 0
+	for i < len(body) {
+		// skip whitespace
+		for i < len(body) && isSmaliSpace(body[i]) {
+			i++
+		}
+		start := i
+		for i < len(body) && !isSmaliSpace(body[i]) && body[i] != '=' {
+			i++
+		}
+		name := body[start:i]
+		for i < len(body) && isSmaliSpace(body[i]) {
+			i++
+		}
+		if name == "" || i >= len(body) || body[i] != '=' {
+			// not an element, skip the rest of the line
+			for i < len(body) && body[i] != '\n' {
+				i++
+			}
+			continue
+		}
+		i++ // '='
+		for i < len(body) && (body[i] == ' ' || body[i] == '\t') {
+			i++
+		}
+		end := scanAnnotationValue(body, i)
+		elements[name] = strings.TrimSpace(body[i:end])
+		i = end
+	}
+	return elements
+}
+
+// scanAnnotationValue returns the index just past the value starting at i.
+func scanAnnotationValue(s string, i int) int {
+	switch {
+	case i >= len(s):
+		return i
+	case s[i] == '"':
+		return scanSmaliString(s, i)
+	case s[i] == '{':
+		depth := 0
+		for i < len(s) {
+			switch s[i] {
+			case '"':
+				i = scanSmaliString(s, i)
+				continue
+			case '{':
+				depth++
+			case '}':
+				depth--
+				if depth == 0 {
+					return i + 1
+				}
+			}
+			i++
+		}
+		return i
+	case strings.HasPrefix(s[i:], ".subannotation"):
+		if end := strings.Index(s[i:], ".end subannotation"); end != -1 {
+			return i + end + len(".end subannotation")
+		}
+		return len(s)
+	default:
+		for i < len(s) && s[i] != '\n' {
+			i++
+		}
+		return i
+	}
+}
+
+// scanSmaliString returns the index just past the quoted string starting at i.
+func scanSmaliString(s string, i int) int {
+	i++ // opening quote
+	for i < len(s) {
+		switch s[i] {
+		case '\\':
+			i += 2
+			continue
+		case '"':
+			return i + 1
+		}
+		i++
+	}
+	return i
+}
+
+// splitAnnotationArray returns the items of `{ a, b }`, or the value itself when it isn't an array.
+func splitAnnotationArray(raw string) []string {
+	raw = strings.TrimSpace(raw)
+	if !strings.HasPrefix(raw, "{") {
+		if raw == "" {
+			return nil
+		}
+		return []string{raw}
+	}
+	inner := strings.TrimSuffix(strings.TrimPrefix(raw, "{"), "}")
+	var items []string
+	i := 0
+	for i < len(inner) {
+		for i < len(inner) && (isSmaliSpace(inner[i]) || inner[i] == ',') {
+			i++
+		}
+		if i >= len(inner) {
+			break
+		}
+		start := i
+		if inner[i] == '"' {
+			i = scanSmaliString(inner, i)
+		} else {
+			for i < len(inner) && inner[i] != ',' && inner[i] != '\n' {
+				i++
+			}
+		}
+		items = append(items, strings.TrimSpace(inner[start:i]))
+	}
+	return items
+}
+
+// unquoteSmali decodes a smali string literal, e.g. `"a\u0000b"`.
+// Values that aren't quoted are returned unchanged.
+func unquoteSmali(s string) string {
+	s = strings.TrimSpace(s)
+	if len(s) < 2 || s[0] != '"' || s[len(s)-1] != '"' {
+		return s
+	}
+	s = s[1 : len(s)-1]
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c != '\\' || i+1 >= len(s) {
+			b.WriteByte(c)
+			continue
+		}
+		i++
+		switch s[i] {
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		case 't':
+			b.WriteByte('\t')
+		case 'b':
+			b.WriteByte('\b')
+		case 'f':
+			b.WriteByte('\f')
+		case 'u':
+			if i+4 < len(s) {
+				if r, err := strconv.ParseUint(s[i+1:i+5], 16, 32); err == nil {
+					b.WriteRune(rune(r))
+					i += 4
+					continue
+				}
+			}
+			b.WriteByte('u')
+		default:
+			// \" \' \\ and anything unknown => literal char
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String()
+}
+
+func isSmaliSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
@@ -0,0 +1,47 @@
+package parser
+
+import "testing"
+
+func TestParseAnnotations(t *testing.T) {
+	body := `
+    .annotation runtime Lretrofit2/http/Headers;
+        value = {
+            "Accept: application/json",
+            "X-Brace: {x}, \"quoted\""
+        }
+    .end annotation
+
+    .annotation runtime Lretrofit2/http/HTTP;
+        hasBody = true
+        method = "DELETE"
+        path = "a/b"
+    .end annotation
+`
+	annotations := parseAnnotations(body)
+	if len(annotations) != 2 {
+		t.Fatalf("Expected 2 annotations, got %d", len(annotations))
+	}
+
+	headers := annotations[0].Strings("value")
+	if len(headers) != 2 || headers[0] != "Accept: application/json" || headers[1] != `X-Brace: {x}, "quoted"` {
+		t.Errorf("Unexpected header values: %q", headers)
+	}
+
+	http, ok := findAnnotation(annotations, "Lretrofit2/http/HTTP;")
+	if !ok {
+		t.Fatal("Expected to find @HTTP")
+	}
+	if m, _ := http.String("method"); m != "DELETE" {
+		t.Errorf("Expected method DELETE, got %q", m)
+	}
+	if !http.Bool("hasBody") {
+		t.Errorf("Expected hasBody to be true")
+	}
+}
+
+func TestUnquoteSmali(t *testing.T) {
+	got := unquoteSmali(`"a\u0000b\n\'c\""`)
+	if got != "a\x00b\n'c\"" {
+		t.Errorf("Unexpected unquoted value %q", got)
+	}
+}
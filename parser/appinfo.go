@@ -0,0 +1,185 @@
+package parser
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	swagger "github.com/go-openapi/spec"
+	"gopkg.in/yaml.v3"
+)
+
+// --------------------------------------------------------------------------
+// Spec info, from the AndroidManifest.xml and apktool.yml of an apktool output
+// --------------------------------------------------------------------------
+
+// appInfo describes the app build the API was extracted from
+type appInfo struct {
+	Package     string // e.g. "com.example.app"
+	Label       string // android:label of the application, possibly a "@string/app_name" reference
+	VersionName string
+	VersionCode string
+	MinSDK      string
+	TargetSDK   string
+}
+
+// App found by LoadAppInfo, empty for bare smali
+var app appInfo
+
+// Info title and version given on the command line, they win over the app's
+var titleOverride, versionOverride string
+
+// Layout of the decoded AndroidManifest.xml
+type manifestXML struct {
+	Package     string `xml:"package,attr"`
+	VersionName string `xml:"http://schemas.android.com/apk/res/android versionName,attr"`
+	VersionCode string `xml:"http://schemas.android.com/apk/res/android versionCode,attr"`
+	UsesSDK     struct {
+		MinSDK    string `xml:"http://schemas.android.com/apk/res/android minSdkVersion,attr"`
+		TargetSDK string `xml:"http://schemas.android.com/apk/res/android targetSdkVersion,attr"`
+	} `xml:"uses-sdk"`
+	Application struct {
+		Label string `xml:"http://schemas.android.com/apk/res/android label,attr"`
+	} `xml:"application"`
+}
+
+// Layout of apktool.yml
+type apktoolYML struct {
+	SdkInfo struct {
+		MinSDK    string `yaml:"minSdkVersion"`
+		TargetSDK string `yaml:"targetSdkVersion"`
+	} `yaml:"sdkInfo"`
+	VersionInfo struct {
+		VersionCode string `yaml:"versionCode"`
+		VersionName string `yaml:"versionName"`
+	} `yaml:"versionInfo"`
+}
+
+// LoadAppInfo reads the package, label, version and SDK levels of an apktool output directory,
+// dir being its root or its smali directory. Missing files aren't an error, the input may be bare smali.
+func LoadAppInfo(dir string) error {
+	app = appInfo{}
+	root := filepath.Clean(dir)
+	if _, err := os.Stat(filepath.Join(root, "AndroidManifest.xml")); err != nil {
+		if _, err := os.Stat(filepath.Join(filepath.Dir(root), "AndroidManifest.xml")); err == nil {
+			root = filepath.Dir(root)
+		}
+	}
+
+	data, err := os.ReadFile(filepath.Join(root, "AndroidManifest.xml"))
+	switch {
+	case os.IsNotExist(err):
+		log.Printf("No AndroidManifest.xml in %s, the spec info won't name the app", root)
+	case err != nil:
+		return err
+	default:
+		var manifest manifestXML
+		if err := xml.Unmarshal(data, &manifest); err != nil {
+			return fmt.Errorf("parsing AndroidManifest.xml: %w", err)
+		}
+		app = appInfo{
+			Package:     manifest.Package,
+			Label:       manifest.Application.Label,
+			VersionName: manifest.VersionName,
+			VersionCode: manifest.VersionCode,
+			MinSDK:      manifest.UsesSDK.MinSDK,
+			TargetSDK:   manifest.UsesSDK.TargetSDK,
+		}
+	}
+
+	data, err = os.ReadFile(filepath.Join(root, "apktool.yml"))
+	switch {
+	case os.IsNotExist(err):
+		return nil
+	case err != nil:
+		return err
+	}
+	// older apktool versions tag the document with its java class, e.g. !!brut.androlib.meta.MetaInfo
+	if bytes.HasPrefix(data, []byte("!!")) {
+		if i := bytes.IndexByte(data, '\n'); i != -1 {
+			data = data[i+1:]
+		}
+	}
+	var meta apktoolYML
+	if err := yaml.Unmarshal(data, &meta); err != nil {
+		return fmt.Errorf("parsing apktool.yml: %w", err)
+	}
+	// apktool moves the version and SDK levels out of the manifest
+	for _, v := range []struct {
+		dst *string
+		src string
+	}{
+		{&app.VersionName, meta.VersionInfo.VersionName},
+		{&app.VersionCode, meta.VersionInfo.VersionCode},
+		{&app.MinSDK, meta.SdkInfo.MinSDK},
+		{&app.TargetSDK, meta.SdkInfo.TargetSDK},
+	} {
+		if v.src != "" {
+			*v.dst = v.src
+		}
+	}
+	log.Printf("App %s version %s (%s)", app.Package, app.VersionName, app.VersionCode)
+	return nil
+}
+
+// SetInfo overrides the info title and version otherwise taken from the app, empty values are ignored
+func SetInfo(title, version string) {
+	titleOverride, versionOverride = title, version
+}
+
+// buildInfo names and versions the spec after the app, e.g. "Example API" 2.4.1, keeping the build details in extensions
+func buildInfo() *swagger.Info {
+	info := &swagger.Info{
+		InfoProps: swagger.InfoProps{
+			Title:       "Extracted API",
+			Version:     "1.0.0",
+			Description: "API extracted from Smali files",
+		},
+	}
+	if label := app.label(); label != "" {
+		info.Title = label + " API"
+	} else if app.Package != "" {
+		info.Title = app.Package + " API"
+	}
+	if app.VersionName != "" {
+		info.Version = app.VersionName
+	}
+	if app.Package != "" {
+		info.Description = fmt.Sprintf("API extracted from the Smali files of %s", app.Package)
+	}
+	if titleOverride != "" {
+		info.Title = titleOverride
+	}
+	if versionOverride != "" {
+		info.Version = versionOverride
+	}
+
+	for name, value := range map[string]string{
+		"x-package-name":       app.Package,
+		"x-version-name":       app.VersionName,
+		"x-version-code":       app.VersionCode,
+		"x-min-sdk-version":    app.MinSDK,
+		"x-target-sdk-version": app.TargetSDK,
+	} {
+		if value != "" {
+			info.AddExtension(name, value)
+		}
+	}
+	return info
+}
+
+// label returns the application label, resolving a "@string/..." reference from the loaded resources
+func (a appInfo) label() string {
+	name, ok := strings.CutPrefix(a.Label, "@string/")
+	if !ok {
+		return a.Label
+	}
+	if values := stringResources[name]; len(values) > 0 {
+		return values[0].Value
+	}
+	return ""
+}
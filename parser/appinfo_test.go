@@ -0,0 +1,43 @@
+package parser
+
+import (
+	"testing"
+)
+
+func TestAppInfo(t *testing.T) {
+	isolateGlobals(t)
+	if err := LoadResources("testdata/apktool"); err != nil {
+		t.Fatal(err)
+	}
+	// the smali directory of an apktool output finds its manifest one level up
+	if err := LoadAppInfo("testdata/apktool/smali"); err != nil {
+		t.Fatal(err)
+	}
+
+	spec, err := GenerateSwaggerSpec(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if spec.Info.Title != "Example API" || spec.Info.Version != "2.4.1" {
+		t.Errorf("Expected the app label and version name, got %q %q", spec.Info.Title, spec.Info.Version)
+	}
+	for name, want := range map[string]string{
+		"x-package-name":       "com.example.app",
+		"x-version-code":       "42",
+		"x-min-sdk-version":    "24",
+		"x-target-sdk-version": "34",
+	} {
+		if got := spec.Info.Extensions[name]; got != want {
+			t.Errorf("Expected %s %s, got %v", name, want, got)
+		}
+	}
+
+	SetInfo("Example internal API", "2024.1")
+	spec, err = GenerateSwaggerSpec(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if spec.Info.Title != "Example internal API" || spec.Info.Version != "2024.1" {
+		t.Errorf("Expected the overrides to win, got %q %q", spec.Info.Title, spec.Info.Version)
+	}
+}
@@ -0,0 +1,662 @@
+package parser
+
+import (
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	swagger "github.com/go-openapi/spec"
+)
+
+// --------------------------------------------------------------------------
+// Base URLs, from the strings handed to Retrofit.Builder.baseUrl
+// --------------------------------------------------------------------------
+
+// Regex for any method with its modifiers and body, including constructors and static initializers
+var methodBlockPattern = regexp.MustCompile(
+	`(?m)^\.method[ \t]+((?:[\w-]+[ \t]+)*)([\w$<>-]+)\(([^)]*)\)(\S+)[ \t]*$([\s\S]*?)^\.end method`)
+
+// Regexes for the instructions a string can flow through into a register
+var (
+	constStringPattern  = regexp.MustCompile(`^const-string(?:/jumbo)?\s+([vp]\d+),\s+(".*")$`)
+	sgetStringPattern   = regexp.MustCompile(`^sget-object\s+([vp]\d+),\s+(L[^;]+;)->([^:]+):Ljava/lang/String;$`)
+	sputStringPattern   = regexp.MustCompile(`^sput-object\s+([vp]\d+),\s+(L[^;]+;)->([^:]+):Ljava/lang/String;$`)
+	moveObjectPattern   = regexp.MustCompile(`^move-object(?:/from16|/16)?\s+([vp]\d+),\s+([vp]\d+)$`)
+	moveResultPattern   = regexp.MustCompile(`^move-result-object\s+([vp]\d+)$`)
+	invokePattern       = regexp.MustCompile(`^invoke-[\w-]+(?:/range)?\s+\{([^}]*)\},\s+(L[^;]+;)->([^(]+)\(([^)]*)\)(\S+)$`)
+	returnObjectPattern = regexp.MustCompile(`^return-object\s+([vp]\d+)$`)
+	// any instruction on a register, e.g. `iget-object v0, p0, ...`
+	registerInstructionPattern = regexp.MustCompile(`^([a-z][\w/-]*)\s+([vp]\d+)\b`)
+)
+
+// Instructions that only read their first register
+var registerReadPrefixes = []string{
+	"sput", "iput", "aput", "if-", "check-cast", "return", "throw", "monitor-",
+	"fill-array-data", "packed-switch", "sparse-switch",
+}
+
+// How many hops (moves, getters, static fields) resolving a string may take
+const maxResolveDepth = 8
+
+// baseURLSite is a base URL handed to a Retrofit builder
+type baseURLSite struct {
+	URL    string
+	Source string // BuildConfig field or string resource holding the URL, e.g. "R.string.api_base_url (values-de)"
+	Class  string // class calling baseUrl, e.g. "Lcom/example/di/NetworkModule;"
+	Method string // e.g. "provideRetrofit"
+}
+
+// stringValue is a string resolved from the code, with where it came from when that
+// tells which build or resource configuration it belongs to
+type stringValue struct {
+	Value    string
+	Source   string        // e.g. "BuildConfig.API_URL (flavor staging, release)" or "R.string.api_base_url"
+	Variants []stringValue // the same string resource in other resource directories
+}
+
+// Base URLs found by ScanBaseURLs, in discovery order
+var baseURLSites []baseURLSite
+
+// retrofitBinding is what backs a retrofit interface, as far as the scan could tell
+type retrofitBinding struct {
+	BaseURL  string
+	Retrofit string // method providing the retrofit instance, e.g. `@Named("feature") NetworkModule.provideFeatureRetrofit`
+	Client   string // method providing its OkHttp client
+	// key of the method building the OkHttp client, e.g. "Lcom/example/di/NetworkModule;->provideClient()"
+	ClientMethod string
+}
+
+// Retrofit instance creating each interface, e.g. "Lcom/example/FeaturesApi;" => {https://..., ...}
+var interfaceBindings = map[string]retrofitBinding{}
+
+// Retrofit instance returned by a method, keyed like "Lcom/example/di/NetworkModule;->provideRetrofit(...)"
+var retrofitProviders = map[string]flowValue{}
+
+// retrofitCreate is a Retrofit.create(SomeApi.class) call, bound to its instance once every provider is known
+type retrofitCreate struct {
+	Method   smaliMethodBlock // method calling create
+	Retrofit flowValue        // the retrofit instance, possibly a param of Method
+	API      string           // e.g. "Lcom/example/FeaturesApi;"
+}
+
+// smaliMethodBlock is a method of a scanned class, ready for the dataflow
+type smaliMethodBlock struct {
+	Class, Name, ParamsSig, ReturnType string
+	Static                             bool
+	Body                               string
+	Lines                              []string
+}
+
+// key identifies the method the way invokes refer to it, e.g. "Lfoo/Bar;->baz(I)"
+func (m smaliMethodBlock) key() string {
+	return m.Class + "->" + m.Name + "(" + m.ParamsSig + ")"
+}
+
+// paramIndex maps a parameter register to the index of the parameter, for static methods too
+func (m smaliMethodBlock) paramIndex(register string) int {
+	if m.Static {
+		n, err := strconv.Atoi(strings.TrimPrefix(register, "p"))
+		if err != nil {
+			return -1
+		}
+		register = "p" + strconv.Itoa(n+1)
+	}
+	return paramIndex(m.ParamsSig, register)
+}
+
+// paramRegisters returns the register holding each parameter on entry, e.g. [p1 p3] for (JLfoo;)
+func (m smaliMethodBlock) paramRegisters() []string {
+	params, err := parseDescriptorParams(m.ParamsSig)
+	if err != nil {
+		return nil
+	}
+	reg := 1
+	if m.Static {
+		reg = 0
+	}
+	var regs []string
+	for _, t := range params {
+		regs = append(regs, "p"+strconv.Itoa(reg))
+		reg++
+		if t.isWide() {
+			reg++
+		}
+	}
+	return regs
+}
+
+// parseMethodBlocks returns every method of a class
+func parseMethodBlocks(content string) []smaliMethodBlock {
+	class := ""
+	if m := classHeaderPattern.FindStringSubmatch(content); m != nil {
+		class = m[2]
+	}
+	var methods []smaliMethodBlock
+	for _, m := range methodBlockPattern.FindAllStringSubmatch(content, -1) {
+		methods = append(methods, smaliMethodBlock{
+			Class: class, Name: m[2], ParamsSig: m[3], ReturnType: m[4],
+			Static: strings.Contains(" "+m[1], " static "), Body: m[5], Lines: instructionLines(m[5]),
+		})
+	}
+	return methods
+}
+
+// ScanBaseURLs looks for Retrofit.Builder.baseUrl(...) calls and resolves the URL passed to them,
+// from a const-string, a static final String field or a getter returning either. It then follows
+// the builder through build() into Retrofit.create(SomeApi.class) to tell which interface talks to which host.
+// Retrofit instances, URLs and OkHttp clients injected by Dagger/Hilt are matched to their @Provides methods.
+func ScanBaseURLs(files []string) error {
+	log.Printf("Scanning %d smali files for retrofit base URLs...", len(files))
+	baseURLSites = nil
+	interfaceBindings = map[string]retrofitBinding{}
+	retrofitProviders = map[string]flowValue{}
+
+	var methods []smaliMethodBlock
+	for _, path := range files {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			log.Printf("Could not read %s: %v", path, err)
+			continue
+		}
+		content := string(data)
+		if !strings.Contains(content, "Lretrofit2/Retrofit") && !isDaggerClass(content) {
+			continue
+		}
+		methods = append(methods, parseMethodBlocks(content)...)
+	}
+	graph := newDaggerGraph(methods)
+
+	// the first pass learns which methods return a retrofit instance,
+	// so the second one can follow them into the methods calling create
+	for _, m := range graph.methods {
+		followRetrofitFlow(m, false)
+	}
+	var creates []retrofitCreate
+	for _, m := range graph.methods {
+		creates = append(creates, followRetrofitFlow(m, true)...)
+	}
+	for _, c := range creates {
+		b, ok := graph.bind(c)
+		if !ok {
+			continue
+		}
+		log.Printf("Interface %s is created by %s->%s with %+v", c.API, c.Method.Class, c.Method.Name, b)
+		interfaceBindings[c.API] = b
+	}
+	return nil
+}
+
+// flowKind is what a register holds as far as the dataflow is concerned
+type flowKind int
+
+const (
+	flowBuilder  flowKind = iota + 1 // a Retrofit.Builder
+	flowRetrofit                     // a built Retrofit
+	flowClass                        // a class literal from const-class
+	flowParam                        // a parameter of the method, as it was passed in
+	flowClient                       // an OkHttpClient
+)
+
+type flowValue struct {
+	kind        flowKind
+	url         string // base URL of a builder or retrofit instance, empty when unknown
+	class       string // e.g. "Lcom/example/FeaturesApi;" for a class literal
+	param       int    // 1-based index of the parameter held by a flowParam
+	urlParam    int    // parameter handed to baseUrl when the URL is injected
+	clientParam int    // parameter handed to client(...)
+	provider    string // method returning the retrofit instance or building the client
+	client      string // method building the OkHttp client handed to client(...), when not injected
+}
+
+// Regexes for the instructions the dataflow follows, besides invokes and moves
+var (
+	newInstancePattern = regexp.MustCompile(`^new-instance\s+([vp]\d+),\s+(L[^;]+;)$`)
+	constClassPattern  = regexp.MustCompile(`^const-class\s+([vp]\d+),\s+(L[^;]+;)$`)
+)
+
+// followRetrofitFlow runs a forward, branch insensitive dataflow over a method: builders get their
+// base URL from baseUrl, keep it through the builder chain and build(), and hand it to the interfaces
+// created from the result. Params are tracked too, so injected URLs and clients can be looked up
+// later. With record set it stores base URL sites and returns the create calls, otherwise
+// it only learns which methods return a retrofit instance.
+func followRetrofitFlow(m smaliMethodBlock, record bool) []retrofitCreate {
+	regs := map[string]flowValue{}
+	for i, reg := range m.paramRegisters() {
+		regs[reg] = flowValue{kind: flowParam, param: i + 1}
+	}
+	var creates []retrofitCreate
+	var result *flowValue // value of the last invoke, for move-result-object
+	for i, line := range m.Lines {
+		if strings.HasPrefix(line, "invoke-") {
+			result = nil
+			call := invokePattern.FindStringSubmatch(line)
+			if call == nil {
+				continue
+			}
+			args := splitRegisters(call[1])
+			class, method, returnType := call[2], call[3], call[5]
+			switch {
+			case class == "Lretrofit2/Retrofit$Builder;" && method == "baseUrl" && len(args) == 2:
+				builder := regs[args[0]]
+				builder.kind = flowBuilder
+				u, ok := resolveStringRegister(m.Lines, i, args[1], 0)
+				builder.url, builder.urlParam = u.Value, 0
+				if injected := regs[args[1]]; !ok && injected.kind == flowParam {
+					// resolved from the provider of the param once the dagger graph is known
+					builder.urlParam = injected.param
+				} else if record {
+					if !ok {
+						addDiagnostic("%s->%s: could not resolve the base URL passed to Retrofit", m.Class, m.Name)
+					} else {
+						addBaseURLSite(u, m)
+					}
+				}
+				// the builder is changed in place, the result is often ignored
+				regs[args[0]] = builder
+				result = &builder
+			case class == "Lretrofit2/Retrofit$Builder;" && method == "client" && len(args) == 2:
+				builder := regs[args[0]]
+				switch client := regs[args[1]]; client.kind {
+				case flowParam:
+					builder.clientParam = client.param
+				case flowClient:
+					builder.client = client.provider
+				}
+				regs[args[0]] = builder
+				result = &builder
+			case class == "Lretrofit2/Retrofit$Builder;" && method == "build" && len(args) == 1:
+				builder := regs[args[0]]
+				result = &flowValue{
+					kind: flowRetrofit, url: builder.url, urlParam: builder.urlParam,
+					clientParam: builder.clientParam, client: builder.client,
+				}
+			case class == "Lretrofit2/Retrofit$Builder;" && returnType == class && len(args) > 0:
+				// addConverterFactory(...), addCallAdapterFactory(...), ... return the same builder
+				v := regs[args[0]]
+				result = &v
+			case class == "Lretrofit2/Retrofit;" && method == "create" && len(args) == 2:
+				retrofit, api := regs[args[0]], regs[args[1]]
+				if record && (retrofit.kind == flowRetrofit || retrofit.kind == flowParam) && api.kind == flowClass {
+					creates = append(creates, retrofitCreate{Method: m, Retrofit: retrofit, API: api.class})
+				}
+			case class == "Lokhttp3/OkHttpClient$Builder;" && method == "build":
+				result = &flowValue{kind: flowClient, provider: m.key()}
+			case returnType == "Lokhttp3/OkHttpClient;" && !strings.HasPrefix(class, "Lokhttp3/"):
+				result = &flowValue{kind: flowClient, provider: class + "->" + method + "(" + call[4] + ")"}
+			case returnType == "Lretrofit2/Retrofit;":
+				key := class + "->" + method + "(" + call[4] + ")"
+				if v, ok := retrofitProviders[key]; ok {
+					result = &flowValue{kind: flowRetrofit, url: v.url, provider: key}
+				}
+			}
+			continue
+		}
+
+		if mr := moveResultPattern.FindStringSubmatch(line); mr != nil {
+			if result != nil {
+				regs[mr[1]] = *result
+			} else {
+				delete(regs, mr[1])
+			}
+			result = nil
+			continue
+		}
+		result = nil
+		switch {
+		case newInstancePattern.MatchString(line):
+			ni := newInstancePattern.FindStringSubmatch(line)
+			if ni[2] == "Lretrofit2/Retrofit$Builder;" {
+				regs[ni[1]] = flowValue{kind: flowBuilder}
+			} else {
+				delete(regs, ni[1])
+			}
+		case constClassPattern.MatchString(line):
+			cc := constClassPattern.FindStringSubmatch(line)
+			regs[cc[1]] = flowValue{kind: flowClass, class: cc[2]}
+		case moveObjectPattern.MatchString(line):
+			mo := moveObjectPattern.FindStringSubmatch(line)
+			if v, ok := regs[mo[2]]; ok {
+				regs[mo[1]] = v
+			} else {
+				delete(regs, mo[1])
+			}
+		case returnObjectPattern.MatchString(line):
+			ro := returnObjectPattern.FindStringSubmatch(line)
+			if v := regs[ro[1]]; v.kind == flowRetrofit && m.ReturnType == "Lretrofit2/Retrofit;" {
+				retrofitProviders[m.key()] = v
+			}
+		default:
+			if rw := registerInstructionPattern.FindStringSubmatch(line); rw != nil && writesRegister(line, rw[2]) {
+				delete(regs, rw[2])
+			}
+		}
+	}
+	return creates
+}
+
+// addBaseURLSite records a base URL handed to the builder of m, once per method,
+// and the values the same string resource takes in other resource directories
+func addBaseURLSite(u stringValue, m smaliMethodBlock) {
+next:
+	for _, v := range append([]stringValue{u}, u.Variants...) {
+		for _, s := range baseURLSites {
+			if s.URL == v.Value && s.Class == m.Class && s.Method == m.Name {
+				continue next
+			}
+		}
+		log.Printf("Found base URL %s in %s->%s", v.Value, m.Class, m.Name)
+		baseURLSites = append(baseURLSites, baseURLSite{URL: v.Value, Source: v.Source, Class: m.Class, Method: m.Name})
+	}
+}
+
+// instructionLines returns the trimmed instructions of a method body, without
+// blank lines, comments and debug directives like .line
+func instructionLines(body string) []string {
+	var lines []string
+	for _, line := range strings.Split(body, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ".line") ||
+			strings.HasPrefix(line, ".local") || strings.HasPrefix(line, ".end local") ||
+			strings.HasPrefix(line, ".prologue") {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// splitRegisters splits the register list of an invoke, e.g. "p0, v1" => [p0 v1] or "v0 .. v2" => [v0 v1 v2]
+func splitRegisters(list string) []string {
+	var regs []string
+	if first, last, ok := strings.Cut(list, ".."); ok {
+		// invoke/range, e.g. {v0 .. v5}
+		first, last = strings.TrimSpace(first), strings.TrimSpace(last)
+		if first == "" || last == "" || first[0] != last[0] {
+			return nil
+		}
+		from, err1 := strconv.Atoi(first[1:])
+		to, err2 := strconv.Atoi(last[1:])
+		if err1 != nil || err2 != nil {
+			return nil
+		}
+		for n := from; n <= to; n++ {
+			regs = append(regs, first[:1]+strconv.Itoa(n))
+		}
+		return regs
+	}
+	for _, r := range strings.Split(list, ",") {
+		if r = strings.TrimSpace(r); r != "" {
+			regs = append(regs, r)
+		}
+	}
+	return regs
+}
+
+// resolveStringRegister walks back from lines[idx] to the string last put in reg. It follows moves,
+// static String fields, HttpUrl.get/parse, Uri.parse, string resources and getters returning a constant, but not branches.
+func resolveStringRegister(lines []string, idx int, reg string, depth int) (stringValue, bool) {
+	if depth > maxResolveDepth {
+		return stringValue{}, false
+	}
+	for i := idx - 1; i >= 0; i-- {
+		line := lines[i]
+		if m := constStringPattern.FindStringSubmatch(line); m != nil && m[1] == reg {
+			return stringValue{Value: unquoteSmali(m[2])}, true
+		}
+		if m := sgetStringPattern.FindStringSubmatch(line); m != nil && m[1] == reg {
+			return resolveStaticString(m[2], m[3], depth+1)
+		}
+		if m := moveObjectPattern.FindStringSubmatch(line); m != nil && m[1] == reg {
+			return resolveStringRegister(lines, i, m[2], depth+1)
+		}
+		if m := moveResultPattern.FindStringSubmatch(line); m != nil && m[1] == reg {
+			if i == 0 {
+				return stringValue{}, false
+			}
+			return resolveInvokeResult(lines, i-1, depth+1)
+		}
+		if writesRegister(line, reg) {
+			// overwritten by something we don't follow
+			return stringValue{}, false
+		}
+	}
+	return stringValue{}, false
+}
+
+// writesRegister reports whether the instruction puts something in reg
+func writesRegister(line, reg string) bool {
+	m := registerInstructionPattern.FindStringSubmatch(line)
+	if m == nil || m[2] != reg {
+		return false
+	}
+	for _, prefix := range registerReadPrefixes {
+		if strings.HasPrefix(m[1], prefix) {
+			return false
+		}
+	}
+	return true
+}
+
+// resolveInvokeResult resolves the string returned by the invoke at lines[idx]
+func resolveInvokeResult(lines []string, idx int, depth int) (stringValue, bool) {
+	m := invokePattern.FindStringSubmatch(lines[idx])
+	if m == nil {
+		return stringValue{}, false
+	}
+	regs := splitRegisters(m[1])
+	class, method, returnType := m[2], m[3], m[5]
+	switch {
+	case (class == "Lokhttp3/HttpUrl;" || class == "Lokhttp3/HttpUrl$Companion;") &&
+		(method == "get" || method == "parse") && m[4] == "Ljava/lang/String;" && len(regs) > 0:
+		// HttpUrl.get(String), or HttpUrl.Companion.get(String) from kotlin
+		return resolveStringRegister(lines, idx, regs[len(regs)-1], depth)
+	case class == "Landroid/net/Uri;" && method == "parse" && m[4] == "Ljava/lang/String;" && len(regs) == 1:
+		// Uri.parse(String), e.g. the endpoints of an AppAuth configuration
+		return resolveStringRegister(lines, idx, regs[0], depth)
+	case returnType == "Ljava/lang/String;" && m[4] == "":
+		// a getter, e.g. Config.getBaseUrl() or Config$Companion.getBASE_URL()
+		return resolveGetterString(class, method, depth)
+	case method == "getString" && m[4] == "I" && returnType == "Ljava/lang/String;" && len(regs) == 2:
+		// Context.getString(R.string.x) or Resources.getString(R.string.x)
+		name, ok := resolveStringResourceName(lines, idx, regs[1], depth)
+		if !ok {
+			return stringValue{}, false
+		}
+		return stringResourceValue(name)
+	}
+	return stringValue{}, false
+}
+
+// resolveStaticString resolves the value of a static String field, from its initial value or <clinit>.
+// BuildConfig fields say which flavor and build type they come from.
+func resolveStaticString(class, field string, depth int) (stringValue, bool) {
+	content, ok := readClassContent(class)
+	if !ok {
+		return stringValue{}, false
+	}
+	v, ok := staticStringValue(content, class, field, depth)
+	if ok && strings.HasSuffix(class, "/BuildConfig;") {
+		v.Source = "BuildConfig." + field + buildConfigVariant(content, class)
+	}
+	return v, ok
+}
+
+func staticStringValue(content, class, field string, depth int) (stringValue, bool) {
+	for _, f := range parseSmaliFields(content) {
+		if f.Name == field && strings.HasPrefix(f.Value, `"`) {
+			return stringValue{Value: unquoteSmali(f.Value)}, true
+		}
+	}
+	for _, m := range parseMethodBlocks(content) {
+		if m.Name != "<clinit>" {
+			continue
+		}
+		lines := m.Lines
+		for i, line := range lines {
+			if s := sputStringPattern.FindStringSubmatch(line); s != nil && s[2] == class && s[3] == field {
+				return resolveStringRegister(lines, i, s[1], depth+1)
+			}
+		}
+	}
+	return stringValue{}, false
+}
+
+// buildConfigVariant describes the build a BuildConfig belongs to, e.g. " (flavor staging, release)"
+func buildConfigVariant(content, class string) string {
+	var parts []string
+	if flavor, ok := staticStringValue(content, class, "FLAVOR", maxResolveDepth); ok && flavor.Value != "" {
+		parts = append(parts, "flavor "+flavor.Value)
+	}
+	if buildType, ok := staticStringValue(content, class, "BUILD_TYPE", maxResolveDepth); ok && buildType.Value != "" {
+		parts = append(parts, buildType.Value)
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return " (" + strings.Join(parts, ", ") + ")"
+}
+
+// resolveGetterString resolves the string returned by a method without params
+func resolveGetterString(class, method string, depth int) (stringValue, bool) {
+	content, ok := readClassContent(class)
+	if !ok {
+		return stringValue{}, false
+	}
+	for _, m := range parseMethodBlocks(content) {
+		if m.Name == method && m.ParamsSig == "" {
+			return resolveReturnedString(m, depth+1)
+		}
+	}
+	return stringValue{}, false
+}
+
+// resolveReturnedString resolves the string returned by the last return-object of m
+func resolveReturnedString(m smaliMethodBlock, depth int) (stringValue, bool) {
+	for i := len(m.Lines) - 1; i >= 0; i-- {
+		if r := returnObjectPattern.FindStringSubmatch(m.Lines[i]); r != nil {
+			return resolveStringRegister(m.Lines, i, r[1], depth)
+		}
+	}
+	return stringValue{}, false
+}
+
+// readClassContent reads the smali of a scanned class
+func readClassContent(class string) (string, bool) {
+	filePath, ok := classToFilePath[class]
+	if !ok {
+		return "", false
+	}
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		log.Printf("Could not read %s: %v", filePath, err)
+		return "", false
+	}
+	return string(data), true
+}
+
+// distinctBaseURLs returns each base URL once, in discovery order
+func distinctBaseURLs() []string {
+	var urls []string
+	seen := map[string]bool{}
+	for _, s := range baseURLSites {
+		if !seen[s.URL] {
+			seen[s.URL] = true
+			urls = append(urls, s.URL)
+		}
+	}
+	return urls
+}
+
+// baseURLTag returns the tag grouping the operations of a base URL, e.g. "features.example.com/api"
+// for https://features.example.com/api/, adding it to the spec the first time
+func baseURLTag(spec *swagger.Swagger, baseURL string) string {
+	name := strings.TrimSuffix(baseURL, "/")
+	if u, err := url.Parse(baseURL); err == nil && u.Host != "" {
+		name = u.Host + strings.TrimSuffix(u.Path, "/")
+	}
+	for _, tag := range spec.Tags {
+		if tag.Name == name {
+			return name
+		}
+	}
+	tag := swagger.NewTag(name, fmt.Sprintf("Paths relative to %s", baseURL), nil)
+	tag.AddExtension("x-base-url", baseURL)
+	spec.Tags = append(spec.Tags, tag)
+	return name
+}
+
+// applyBaseURLs sets host, basePath and schemes from the base URL most operations are bound
+// to, or the first one found when none is bound. With several base URLs all of them are listed
+// in x-servers, described by the BuildConfig field or string resource they come from.
+func applyBaseURLs(spec *swagger.Swagger, endpoints []*APIEndpoint) {
+	urls := distinctBaseURLs()
+	if len(urls) == 0 {
+		return
+	}
+	parsed := map[string]*url.URL{}
+	for _, raw := range urls {
+		u, err := url.Parse(raw)
+		if err != nil || u.Host == "" {
+			addDiagnostic("base URL %q is not an absolute URL", raw)
+			continue
+		}
+		parsed[raw] = u
+	}
+
+	// operations per base URL, and the hosts they span
+	uses := map[string]int{}
+	var hosts []string
+	seenHosts := map[string]bool{}
+	for _, endpoint := range endpoints {
+		raw := interfaceBindings[endpoint.ClassName].BaseURL
+		u, ok := parsed[raw]
+		if !ok {
+			continue
+		}
+		uses[raw]++
+		if !seenHosts[u.Host] {
+			seenHosts[u.Host] = true
+			hosts = append(hosts, u.Host)
+		}
+	}
+	primary := ""
+	for _, raw := range urls {
+		// ties go to the first found
+		if _, ok := parsed[raw]; ok && (primary == "" || uses[raw] > uses[primary]) {
+			primary = raw
+		}
+	}
+	if primary == "" {
+		return
+	}
+	u := parsed[primary]
+	spec.Host = u.Host
+	spec.BasePath = strings.TrimSuffix(u.Path, "/")
+	spec.Schemes = []string{u.Scheme}
+	if len(hosts) > 1 {
+		addDiagnostic("operations are bound to %d hosts (%s), host is %s which most of them use, see x-base-url for the others",
+			len(hosts), strings.Join(hosts, ", "), u.Host)
+	}
+
+	if len(urls) > 1 {
+		descriptions := map[string]string{}
+		for _, s := range baseURLSites {
+			if s.Source != "" && descriptions[s.URL] == "" {
+				descriptions[s.URL] = s.Source
+			}
+		}
+		var servers []map[string]interface{}
+		for _, u := range urls {
+			server := map[string]interface{}{"url": u}
+			if d := descriptions[u]; d != "" {
+				server["description"] = d
+			}
+			servers = append(servers, server)
+		}
+		spec.AddExtension("x-servers", servers)
+	}
+}
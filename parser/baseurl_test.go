@@ -0,0 +1,320 @@
+package parser
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestScanBaseURLs(t *testing.T) {
+	isolateGlobals(t)
+	classToFilePath["Lcom/example/di/ApiConfig;"] = "testdata/ApiConfig.smali"
+	classToFilePath["Lcom/example/di/NetworkModule;"] = "testdata/NetworkModule.smali"
+	if err := ScanBaseURLs([]string{"testdata/NetworkModule.smali", "testdata/ApiConfig.smali"}); err != nil {
+		t.Fatal(err)
+	}
+
+	urls := distinctBaseURLs()
+	if len(urls) != 3 {
+		t.Fatalf("Expected 3 base URLs, got %v", urls)
+	}
+	for i, want := range []string{"https://auth.example.com/oauth/", "https://api.example.com/v2/", "https://telemetry.example.com/"} {
+		if urls[i] != want {
+			t.Errorf("Expected %s, got %s", want, urls[i])
+		}
+	}
+	if site := baseURLSites[1]; site.Class != "Lcom/example/di/NetworkModule;" || site.Method != "provideRetrofit" {
+		t.Errorf("Unexpected call site %+v", site)
+	}
+
+	spec, err := GenerateSwaggerSpec(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if spec.Host != "auth.example.com" || spec.BasePath != "/oauth" || len(spec.Schemes) != 1 || spec.Schemes[0] != "https" {
+		t.Errorf("Expected host, basePath and schemes from the first base URL as no operation is bound, got %s %s %v", spec.Host, spec.BasePath, spec.Schemes)
+	}
+	if servers, _ := spec.Extensions["x-servers"].([]map[string]interface{}); len(servers) != 3 {
+		t.Errorf("Expected x-servers with every base URL, got %v", spec.Extensions["x-servers"])
+	}
+}
+
+const authApiSmali = `.class public interface abstract Lcom/example/AuthApi;
+.super Ljava/lang/Object;
+
+# virtual methods
+.method public abstract token()Lretrofit2/Call;
+    .annotation runtime Lretrofit2/http/POST;
+        value = "token"
+    .end annotation
+.end method
+`
+
+const telemetryApiSmali = `.class public interface abstract Lcom/example/TelemetryApi;
+.super Ljava/lang/Object;
+
+# virtual methods
+.method public abstract events()Lretrofit2/Call;
+    .annotation runtime Lretrofit2/http/POST;
+        value = "events"
+    .end annotation
+.end method
+
+.method public abstract metrics()Lretrofit2/Call;
+    .annotation runtime Lretrofit2/http/POST;
+        value = "metrics"
+    .end annotation
+.end method
+`
+
+func TestInterfaceBaseURLs(t *testing.T) {
+	isolateGlobals(t)
+	classToFilePath["Lcom/example/di/ApiConfig;"] = "testdata/ApiConfig.smali"
+	classToFilePath["Lcom/example/di/NetworkModule;"] = "testdata/NetworkModule.smali"
+	if err := ScanBaseURLs([]string{"testdata/NetworkModule.smali"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if u := interfaceBindings["Lcom/example/AuthApi;"].BaseURL; u != "https://auth.example.com/oauth/" {
+		t.Errorf("Expected AuthApi to go through the retrofit instance returned by provideAuthRetrofit, got %q", u)
+	}
+	if u := interfaceBindings["Lcom/example/TelemetryApi;"].BaseURL; u != "https://telemetry.example.com/" {
+		t.Errorf("Expected TelemetryApi to get the base URL of its builder, got %q", u)
+	}
+
+	apis, err := ExtractAPIEndpoints(authApiSmali)
+	if err != nil {
+		t.Fatal(err)
+	}
+	spec, err := GenerateSwaggerSpec(apis)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u := spec.Paths.Paths["/token"].Post.Extensions["x-base-url"]; u != "https://auth.example.com/oauth/" {
+		t.Errorf("Expected the operation to carry its base URL, got %v", u)
+	}
+	if spec.Host != "auth.example.com" || spec.BasePath != "/oauth" {
+		t.Errorf("Expected the host and basePath of the only bound operation, got %s %s", spec.Host, spec.BasePath)
+	}
+
+	telemetry, err := ExtractAPIEndpoints(telemetryApiSmali)
+	if err != nil {
+		t.Fatal(err)
+	}
+	diagnostics = nil
+	spec, err = GenerateSwaggerSpec(append(apis, telemetry...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if spec.Host != "telemetry.example.com" || spec.BasePath != "" {
+		t.Errorf("Expected the host most operations are bound to, got %s %s", spec.Host, spec.BasePath)
+	}
+	for path, want := range map[string]string{"/token": "auth.example.com/oauth", "/events": "telemetry.example.com"} {
+		if tags := spec.Paths.Paths[path].Post.Tags; len(tags) != 1 || tags[0] != want {
+			t.Errorf("%s: expected the operation to be tagged with its base URL %s, got %v", path, want, tags)
+		}
+	}
+	if len(spec.Tags) != 2 || spec.Tags[0].Extensions["x-base-url"] != "https://auth.example.com/oauth/" {
+		t.Errorf("Expected a tag per bound base URL, got %+v", spec.Tags)
+	}
+	found := false
+	for _, d := range Diagnostics() {
+		if strings.Contains(d, "bound to 2 hosts") {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("Expected operations spanning several hosts to be reported, got %v", Diagnostics())
+	}
+}
+
+func TestDaggerBindings(t *testing.T) {
+	isolateGlobals(t)
+	classToFilePath["Lcom/example/di/FeatureModule;"] = "testdata/FeatureModule.smali"
+	classToFilePath["Lcom/example/di/AuthClient;"] = "testdata/AuthClient.smali"
+	// the module is only reached through its generated factory
+	if err := ScanBaseURLs([]string{"testdata/FeatureModule_ProvideFeaturesApiFactory.smali"}); err != nil {
+		t.Fatal(err)
+	}
+
+	b := interfaceBindings["Luk/co/goptions/libs/cloudlib/featureservice/interfaces/FeaturesApi;"]
+	if b.BaseURL != "https://features.example.com/api/" {
+		t.Errorf("Expected the base URL injected by provideFeatureBaseUrl, got %q", b.BaseURL)
+	}
+	if b.Retrofit != `@Named("feature") FeatureModule.provideFeatureRetrofit` {
+		t.Errorf("Expected the named retrofit provider, got %q", b.Retrofit)
+	}
+	if b.Client != "@AuthClient FeatureModule.provideAuthClient" {
+		t.Errorf("Expected the client provider with its custom qualifier, got %q", b.Client)
+	}
+	if urls := distinctBaseURLs(); len(urls) != 1 || baseURLSites[0].Method != "provideFeatureRetrofit" {
+		t.Errorf("Expected the injected base URL to be listed once, got %+v", baseURLSites)
+	}
+
+	data, err := os.ReadFile("testdata/FeaturesApi.smali")
+	if err != nil {
+		t.Fatal(err)
+	}
+	apis, err := ExtractAPIEndpoints(string(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	spec, err := GenerateSwaggerSpec(apis)
+	if err != nil {
+		t.Fatal(err)
+	}
+	get := spec.Paths.Paths["/featureservice/v1"].Get
+	if get.Extensions["x-retrofit-instance"] != b.Retrofit || get.Extensions["x-okhttp-client"] != b.Client {
+		t.Errorf("Expected the operation to carry its retrofit instance and client, got %v", get.Extensions)
+	}
+}
+
+func TestResourceAndBuildConfigBaseURLs(t *testing.T) {
+	isolateGlobals(t)
+	if err := LoadResources("testdata/apktool"); err != nil {
+		t.Fatal(err)
+	}
+	classToFilePath["Lcom/example/BuildConfig;"] = "testdata/BuildConfig.smali"
+	if err := ScanBaseURLs([]string{"testdata/RegionModule.smali"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if v := stringResources["greeting"][0].Value; v != "Don't panic & carry on" {
+		t.Errorf("Expected escapes and entities to be decoded, got %q", v)
+	}
+
+	want := []baseURLSite{
+		{URL: "https://api.example.com/", Source: "R.string.api_base_url"},
+		{URL: "https://api.example.de/", Source: "R.string.api_base_url (values-de)"},
+		{URL: "https://cdn.example.com/v1/", Source: "R.string.cdn_base_url"},
+		{URL: "https://staging.example.com/api/", Source: "BuildConfig.API_URL (flavor staging, release)"},
+	}
+	if len(baseURLSites) != len(want) {
+		t.Fatalf("Expected %d base URLs, got %+v", len(want), baseURLSites)
+	}
+	for i, w := range want {
+		if got := baseURLSites[i]; got.URL != w.URL || got.Source != w.Source {
+			t.Errorf("Expected %+v, got %+v", w, got)
+		}
+	}
+
+	spec, err := GenerateSwaggerSpec(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	servers, _ := spec.Extensions["x-servers"].([]map[string]interface{})
+	if len(servers) != 4 || servers[1]["description"] != "R.string.api_base_url (values-de)" {
+		t.Errorf("Expected a described server per resource directory and build, got %v", servers)
+	}
+}
+
+// scanTestdataClasses registers the classes of testdata/scanned the way main does, from their .class
+// headers. Callers isolate the globals first.
+func scanTestdataClasses(t *testing.T) []string {
+	t.Helper()
+	files, err := filepath.Glob("testdata/scanned/smali/com/example/scanned/*.smali")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ScanAllSmaliClasses(files); err != nil {
+		t.Fatal(err)
+	}
+	return files
+}
+
+func TestScannedStringResourceIDs(t *testing.T) {
+	isolateGlobals(t)
+	files := scanTestdataClasses(t)
+	// no public.xml, the id is looked up in R$string
+	if err := LoadResources("testdata/scanned/smali"); err != nil {
+		t.Fatal(err)
+	}
+	if err := ScanBaseURLs(files); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := classToFilePath["Lcom/example/scanned/R$string;"]; !ok {
+		t.Fatalf("Expected R$string to be registered, got %v", classToFilePath)
+	}
+	found := false
+	for _, site := range baseURLSites {
+		if site.URL == "https://legacy.example.com/" && site.Source == "R.string.legacy_base_url" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("Expected the resource id to be resolved through R$string, got %+v", baseURLSites)
+	}
+}
+
+func TestScannedCompanionBaseURL(t *testing.T) {
+	isolateGlobals(t)
+	files := scanTestdataClasses(t)
+	if err := ScanBaseURLs(files); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, site := range baseURLSites {
+		if site.URL == "https://companion.example.com/api/" && site.Class == "Lcom/example/scanned/CompanionClient;" {
+			return
+		}
+	}
+	t.Errorf("Expected the base URL returned by Endpoints.Companion.getBASE_URL, got %+v", baseURLSites)
+}
+
+func TestSplitRegisters(t *testing.T) {
+	for list, want := range map[string][]string{
+		"p0, v1":    {"p0", "v1"},
+		"v0 .. v3":  {"v0", "v1", "v2", "v3"},
+		"p1 .. p1":  {"p1"},
+		"v16 .. v2": nil,
+		"v0 .. p2":  nil,
+		"":          nil,
+	} {
+		got := splitRegisters(list)
+		if strings.Join(got, " ") != strings.Join(want, " ") {
+			t.Errorf("splitRegisters(%q): expected %v, got %v", list, want, got)
+		}
+	}
+}
+
+const rangeBuilderSmali = `.class public final Lcom/example/RangeClient;
+.super Ljava/lang/Object;
+
+# direct methods
+.method public static create()Lretrofit2/Retrofit;
+    .locals 20
+
+    new-instance v16, Lretrofit2/Retrofit$Builder;
+
+    invoke-direct/range {v16 .. v16}, Lretrofit2/Retrofit$Builder;-><init>()V
+
+    const-string v17, "https://range.example.com/"
+
+    invoke-virtual/range {v16 .. v17}, Lretrofit2/Retrofit$Builder;->baseUrl(Ljava/lang/String;)Lretrofit2/Retrofit$Builder;
+
+    move-result-object v0
+
+    invoke-virtual {v0}, Lretrofit2/Retrofit$Builder;->build()Lretrofit2/Retrofit;
+
+    move-result-object v0
+
+    return-object v0
+.end method
+`
+
+func TestRangeInvokeBaseURL(t *testing.T) {
+	isolateGlobals(t)
+	path := filepath.Join(t.TempDir(), "RangeClient.smali")
+	if err := os.WriteFile(path, []byte(rangeBuilderSmali), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ScanBaseURLs([]string{path}); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(baseURLSites) != 1 || baseURLSites[0].URL != "https://range.example.com/" {
+		t.Errorf("Expected the base URL passed through invoke-virtual/range, got %+v", baseURLSites)
+	}
+}
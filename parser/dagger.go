@@ -0,0 +1,245 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+)
+
+// --------------------------------------------------------------------------
+// Dagger/Hilt modules, to tell which @Provides method hands a retrofit
+// instance, its base URL and its OkHttp client to the code creating an interface
+// --------------------------------------------------------------------------
+
+// isDaggerClass reports whether a class is a dagger module or a generated factory
+func isDaggerClass(content string) bool {
+	return strings.Contains(content, "Ldagger/Module;") || strings.Contains(content, "Ldagger/Provides;") ||
+		strings.Contains(content, "Ldagger/internal/Factory;")
+}
+
+// daggerProvider is a @Provides method, bound to its return type and qualifier
+type daggerProvider struct {
+	Method    smaliMethodBlock
+	Qualifier string // e.g. `@Named("feature")` or `@AuthClient`, empty when unqualified
+}
+
+// daggerGraph indexes the providers of the scanned modules
+type daggerGraph struct {
+	methods    []smaliMethodBlock          // every scanned method, in scan order
+	byKey      map[string]smaliMethodBlock // methods by key()
+	providers  map[string]daggerProvider   // providers by method key()
+	bindings   map[string][]daggerProvider // providers by binding key, e.g. `@Named("feature") Lretrofit2/Retrofit;`
+	qualifiers map[string]bool             // annotation type => is a @Qualifier
+	loaded     map[string]bool             // classes whose methods were added
+}
+
+// newDaggerGraph finds the providers among methods: those annotated @Provides, and the module
+// methods called by generated factories, e.g. NetworkModule_ProvideRetrofitFactory calling
+// NetworkModule.provideRetrofit. Modules only known from their factories are read and added to methods.
+func newDaggerGraph(methods []smaliMethodBlock) *daggerGraph {
+	g := &daggerGraph{
+		byKey:      map[string]smaliMethodBlock{},
+		providers:  map[string]daggerProvider{},
+		bindings:   map[string][]daggerProvider{},
+		qualifiers: map[string]bool{},
+		loaded:     map[string]bool{},
+	}
+	g.add(methods)
+	for _, m := range methods {
+		if !strings.HasSuffix(m.Class, "Factory;") {
+			continue
+		}
+		for _, line := range m.Lines {
+			call := invokePattern.FindStringSubmatch(line)
+			if call == nil || call[2] == m.Class || call[3] == "" ||
+				!strings.HasSuffix(m.Class, "_"+strings.ToUpper(call[3][:1])+call[3][1:]+"Factory;") {
+				continue
+			}
+			key := call[2] + "->" + call[3] + "(" + call[4] + ")"
+			if _, ok := g.providers[key]; ok {
+				continue
+			}
+			if _, ok := g.byKey[key]; !ok {
+				g.load(call[2])
+			}
+			if target, ok := g.byKey[key]; ok {
+				g.addProvider(target)
+			}
+		}
+	}
+	return g
+}
+
+// add indexes methods of scanned classes, and those annotated @Provides as providers
+func (g *daggerGraph) add(methods []smaliMethodBlock) {
+	for _, m := range methods {
+		g.loaded[m.Class] = true
+		g.methods = append(g.methods, m)
+		g.byKey[m.key()] = m
+		if _, ok := findAnnotation(methodAnnotations(m.Body), "Ldagger/Provides;"); ok {
+			g.addProvider(m)
+		}
+	}
+}
+
+// load adds the methods of a class that wasn't scanned, e.g. a module stripped of its annotations
+func (g *daggerGraph) load(class string) {
+	if g.loaded[class] {
+		return
+	}
+	g.loaded[class] = true
+	if content, ok := readClassContent(class); ok {
+		g.add(parseMethodBlocks(content))
+	}
+}
+
+func (g *daggerGraph) addProvider(m smaliMethodBlock) {
+	p := daggerProvider{Method: m, Qualifier: g.qualifierOf(methodAnnotations(m.Body))}
+	g.providers[m.key()] = p
+	key := bindingKey(p.Qualifier, m.ReturnType)
+	g.bindings[key] = append(g.bindings[key], p)
+}
+
+// bindingKey identifies what a provider provides, e.g. `@Named("feature") Lretrofit2/Retrofit;`
+func bindingKey(qualifier, typeSig string) string {
+	return strings.TrimSpace(qualifier + " " + typeSig)
+}
+
+// qualifierOf returns the dagger qualifier among annotations, @Named or any annotation
+// whose class is itself annotated @Qualifier
+func (g *daggerGraph) qualifierOf(annotations []SmaliAnnotation) string {
+	for _, a := range annotations {
+		if a.Type == "Ljavax/inject/Named;" || a.Type == "Ljakarta/inject/Named;" {
+			value, _ := a.String("value")
+			return fmt.Sprintf("@Named(%q)", value)
+		}
+		if g.isQualifier(a.Type) {
+			return "@" + typeShortName(a.Type)
+		}
+	}
+	return ""
+}
+
+func (g *daggerGraph) isQualifier(annotationType string) bool {
+	if q, ok := g.qualifiers[annotationType]; ok {
+		return q
+	}
+	q := false
+	if content, ok := readClassContent(annotationType); ok {
+		annotations := parseSmaliClass(content).Annotations
+		_, javax := findAnnotation(annotations, "Ljavax/inject/Qualifier;")
+		_, jakarta := findAnnotation(annotations, "Ljakarta/inject/Qualifier;")
+		q = javax || jakarta
+	}
+	g.qualifiers[annotationType] = q
+	return q
+}
+
+// providerOf returns the provider injecting the param (1-based) of a provider method
+func (g *daggerGraph) providerOf(m smaliMethodBlock, param int) (daggerProvider, bool) {
+	params, err := parseDescriptorParams(m.ParamsSig)
+	if err != nil || param < 1 || param > len(params) {
+		return daggerProvider{}, false
+	}
+	qualifier := ""
+	for _, b := range findParamBlocks(m.Body) {
+		if m.paramIndex(b.Register) == param-1 {
+			qualifier = g.qualifierOf(parseAnnotations(b.Body))
+		}
+	}
+	key := bindingKey(qualifier, params[param-1].String())
+	providers := g.bindings[key]
+	switch {
+	case len(providers) == 0:
+		addDiagnostic("%s->%s: no dagger provider found for %s", m.Class, m.Name, key)
+		return daggerProvider{}, false
+	case len(providers) > 1:
+		addDiagnostic("%s->%s: %d dagger providers for %s, using %s",
+			m.Class, m.Name, len(providers), key, providers[0])
+	}
+	return providers[0], true
+}
+
+// String describes the provider, e.g. `@Named("feature") NetworkModule.provideFeatureRetrofit`
+func (p daggerProvider) String() string {
+	return strings.TrimSpace(p.Qualifier + " " + methodDisplayName(p.Method))
+}
+
+// methodDisplayName names a method after its class, e.g. NetworkModule.provideRetrofit
+func methodDisplayName(m smaliMethodBlock) string {
+	name := m.Name
+	if original, ok := originalMethodName(m.Class, m.Name, m.ParamsSig); ok {
+		name = original
+	}
+	return typeShortName(m.Class) + "." + name
+}
+
+// describe names the method returning a retrofit instance or client, with its qualifier when it is a provider
+func (g *daggerGraph) describe(m smaliMethodBlock) string {
+	if p, ok := g.providers[m.key()]; ok {
+		return p.String()
+	}
+	return methodDisplayName(m)
+}
+
+// bind follows the retrofit instance of a create call back to the method building it, through
+// injected params and methods returning it, then resolves its injected base URL and client
+func (g *daggerGraph) bind(c retrofitCreate) (retrofitBinding, bool) {
+	var b retrofitBinding
+	owner, retrofit := c.Method, c.Retrofit
+	for depth := 0; retrofit.kind == flowParam || retrofit.provider != ""; depth++ {
+		if depth == maxResolveDepth {
+			return b, false
+		}
+		var next smaliMethodBlock
+		if retrofit.kind == flowParam {
+			if _, ok := g.providers[owner.key()]; !ok {
+				// not a provider, dagger doesn't choose what is passed in
+				return b, false
+			}
+			p, ok := g.providerOf(owner, retrofit.param)
+			if !ok {
+				return b, false
+			}
+			next = p.Method
+		} else {
+			m, ok := g.byKey[retrofit.provider]
+			if !ok {
+				return b, false
+			}
+			next = m
+		}
+		v, ok := retrofitProviders[next.key()]
+		if !ok {
+			return b, false
+		}
+		owner, retrofit = next, v
+		b.Retrofit = g.describe(owner)
+	}
+
+	b.BaseURL = retrofit.url
+	_, injected := g.providers[owner.key()]
+	if injected && b.BaseURL == "" && retrofit.urlParam > 0 {
+		if p, ok := g.providerOf(owner, retrofit.urlParam); ok {
+			if u, ok := resolveReturnedString(p.Method, 0); ok {
+				b.BaseURL = u.Value
+				addBaseURLSite(u, owner)
+			} else {
+				addDiagnostic("%s: could not resolve the base URL returned by %s", methodDisplayName(owner), p)
+			}
+		}
+	}
+	if injected && retrofit.clientParam > 0 {
+		if p, ok := g.providerOf(owner, retrofit.clientParam); ok {
+			b.Client, b.ClientMethod = p.String(), p.Method.key()
+		}
+	} else if retrofit.client != "" {
+		b.ClientMethod = retrofit.client
+		if class, _, ok := strings.Cut(retrofit.client, "->"); ok {
+			g.load(class)
+		}
+		if m, ok := g.byKey[retrofit.client]; ok {
+			b.Client = g.describe(m)
+		}
+	}
+	return b, b.BaseURL != "" || b.Retrofit != "" || b.Client != ""
+}
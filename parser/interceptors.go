@@ -0,0 +1,408 @@
+package parser
+
+import (
+	"log"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	swagger "github.com/go-openapi/spec"
+)
+
+// --------------------------------------------------------------------------
+// OkHttp interceptors, for the headers and query params added to every request
+// of a client: credentials become security definitions, the rest global parameters
+// --------------------------------------------------------------------------
+
+// Regex for a field read keeping its type, e.g. `sget-object v1, Lfoo/HeadersInterceptor;->INSTANCE:Lfoo/HeadersInterceptor;`
+var getObjectPattern = regexp.MustCompile(`^[si]get-object\s+([vp]\d+),\s+(?:[vp]\d+,\s+)?L[^;]+;->[^:]+:(L[^;]+;)$`)
+
+// Regex for header and query param names carrying credentials, e.g. X-Api-Key, access_token
+var credentialNamePattern = regexp.MustCompile(`(?i)(api[-_]?key|token|secret|session|signature|^key$|^auth)`)
+
+// interceptedParam is a header or query param an interceptor adds to every request
+type interceptedParam struct {
+	In     string // "header" or "query"
+	Name   string // e.g. "Authorization"
+	Value  string // constant value, empty when computed at runtime
+	Scheme string // "bearer", "basic" or "apiKey" when it carries credentials, empty otherwise
+}
+
+// Params added by each interceptor class, e.g. "Lcom/example/net/AuthInterceptor;" => [Authorization]
+var interceptorParams = map[string][]interceptedParam{}
+
+// Interceptor classes of the client serving each retrofit interface
+var interfaceInterceptors = map[string][]string{}
+
+// Whether constant API keys are copied into the spec, see ShowSecrets
+var showSecrets bool
+
+// ShowSecrets copies the constant API keys interceptors send into the descriptions of their
+// security definitions, they are redacted otherwise
+func ShowSecrets(show bool) {
+	showSecrets = show
+}
+
+// ScanInterceptors analyzes the classes implementing okhttp3.Interceptor, then links them to the
+// interfaces served by the clients they are added to. It runs after ScanBaseURLs, which tells
+// which method builds the client of each interface.
+func ScanInterceptors(files []string) error {
+	log.Printf("Scanning %d smali files for OkHttp interceptors...", len(files))
+	interceptorParams = map[string][]interceptedParam{}
+	interfaceInterceptors = map[string][]string{}
+
+	for _, path := range files {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			log.Printf("Could not read %s: %v", path, err)
+			continue
+		}
+		content := string(data)
+		if !strings.Contains(content, ".implements Lokhttp3/Interceptor;") {
+			continue
+		}
+		class := parseSmaliClass(content)
+		var params []interceptedParam
+		for _, m := range parseMethodBlocks(content) {
+			params = append(params, interceptedParams(m)...)
+		}
+		log.Printf("Interceptor %s adds %+v", class.Name, params)
+		interceptorParams[class.Name] = params
+	}
+
+	for api, b := range interfaceBindings {
+		if b.ClientMethod == "" {
+			continue
+		}
+		interfaceInterceptors[api] = clientInterceptors(b.ClientMethod)
+	}
+	return nil
+}
+
+// interceptedParams finds the headers and query params a method of an interceptor adds
+func interceptedParams(m smaliMethodBlock) []interceptedParam {
+	var params []interceptedParam
+	for i, line := range m.Lines {
+		call := invokePattern.FindStringSubmatch(line)
+		if call == nil || call[4] != "Ljava/lang/String;Ljava/lang/String;" {
+			continue
+		}
+		args := splitRegisters(call[1])
+		in := ""
+		switch {
+		case call[2] == "Lokhttp3/Request$Builder;" && (call[3] == "addHeader" || call[3] == "header"):
+			in = "header"
+		case call[2] == "Lokhttp3/HttpUrl$Builder;" && (call[3] == "addQueryParameter" || call[3] == "setQueryParameter" ||
+			call[3] == "addEncodedQueryParameter" || call[3] == "setEncodedQueryParameter"):
+			in = "query"
+		}
+		if in == "" || len(args) != 3 {
+			continue
+		}
+		name, ok := resolveStringRegister(m.Lines, i, args[1], 0)
+		if !ok {
+			addDiagnostic("%s->%s: could not resolve the name of a %s added by the interceptor", m.Class, m.Name, in)
+			continue
+		}
+		p := interceptedParam{In: in, Name: name.Value}
+		if value, ok := resolveStringRegister(m.Lines, i, args[2], 0); ok {
+			p.Value = value.Value
+		}
+		p.Scheme = credentialScheme(p, m, i, args[2])
+		params = append(params, p)
+	}
+	return params
+}
+
+// credentialScheme tells how a param carries credentials, from its name and the value put in
+// valueReg before m.Lines[idx], e.g. a "Bearer " prefix or Credentials.basic(...)
+func credentialScheme(p interceptedParam, m smaliMethodBlock, idx int, valueReg string) string {
+	if p.In == "header" && strings.EqualFold(p.Name, "Authorization") {
+		prefix, basic := valuePrefix(m.Lines, idx, valueReg)
+		switch {
+		case basic:
+			return "basic"
+		case strings.HasPrefix(strings.ToLower(prefix), "bearer"):
+			return "bearer"
+		}
+		return "apiKey"
+	}
+	if credentialNamePattern.MatchString(p.Name) {
+		return "apiKey"
+	}
+	return ""
+}
+
+// valuePrefix walks back from lines[idx] to the constant the string in reg starts with, through
+// StringBuilder appends, String.concat, Intrinsics.stringPlus and String.format. It also tells
+// whether the value comes from Credentials.basic(...).
+func valuePrefix(lines []string, idx int, reg string) (prefix string, basic bool) {
+	// the first piece appended to the StringBuilder, the last one seen walking back
+	firstIdx, firstReg := -1, ""
+	builder := false
+	for i := idx - 1; i >= 0; i-- {
+		line := lines[i]
+		if mr := moveResultPattern.FindStringSubmatch(line); mr != nil && mr[1] == reg && i > 0 {
+			call := invokePattern.FindStringSubmatch(lines[i-1])
+			if call == nil {
+				return "", false
+			}
+			args := splitRegisters(call[1])
+			switch {
+			case call[2] == "Lokhttp3/Credentials;" && call[3] == "basic":
+				return "", true
+			case call[2] == "Ljava/lang/StringBuilder;" && call[3] == "append" && len(args) == 2:
+				firstIdx, firstReg, builder = i-1, args[1], true
+				reg = args[0]
+			case call[2] == "Ljava/lang/StringBuilder;" && call[3] == "toString" && len(args) == 1:
+				builder = true
+				reg = args[0]
+			case (call[2] == "Ljava/lang/String;" && (call[3] == "concat" || call[3] == "format") ||
+				call[2] == "Lkotlin/jvm/internal/Intrinsics;" && call[3] == "stringPlus") && len(args) >= 2:
+				// the receiver, the first operand or the format string
+				reg = args[0]
+			default:
+				v, _ := resolveInvokeResult(lines, i-1, 0)
+				return v.Value, false
+			}
+			i-- // the invoke is done
+			continue
+		}
+		if call := invokePattern.FindStringSubmatch(line); call != nil {
+			args := splitRegisters(call[1])
+			// java appends to the builder without keeping their result, or new StringBuilder("Bearer ")
+			if builder && call[2] == "Ljava/lang/StringBuilder;" && len(args) == 2 && args[0] == reg &&
+				(call[3] == "append" || call[3] == "<init>" && call[4] == "Ljava/lang/String;") {
+				firstIdx, firstReg = i, args[1]
+			}
+			continue
+		}
+		if mo := moveObjectPattern.FindStringSubmatch(line); mo != nil && mo[1] == reg {
+			reg = mo[2]
+			continue
+		}
+		if writesRegister(line, reg) {
+			if !builder {
+				// a constant or a static field
+				v, _ := resolveStringRegister(lines, i+1, reg, 0)
+				return v.Value, false
+			}
+			break // the new-instance of the builder
+		}
+	}
+	if firstIdx == -1 {
+		return "", false
+	}
+	v, _ := resolveStringRegister(lines, firstIdx, firstReg, 0)
+	return v.Value, false
+}
+
+// clientInterceptors returns the interceptor classes added to the client built by a method
+func clientInterceptors(methodKey string) []string {
+	class, _, _ := strings.Cut(methodKey, "->")
+	content, ok := readClassContent(class)
+	if !ok {
+		return nil
+	}
+	var classes []string
+	for _, m := range parseMethodBlocks(content) {
+		if m.key() != methodKey {
+			continue
+		}
+		for i, line := range m.Lines {
+			call := invokePattern.FindStringSubmatch(line)
+			if call == nil || call[2] != "Lokhttp3/OkHttpClient$Builder;" ||
+				(call[3] != "addInterceptor" && call[3] != "addNetworkInterceptor") {
+				continue
+			}
+			args := splitRegisters(call[1])
+			if len(args) != 2 {
+				continue
+			}
+			if c, ok := resolveObjectClass(m, i, args[1]); ok {
+				classes = append(classes, c)
+			} else {
+				addDiagnostic("%s: could not tell which interceptor is added to the client", methodDisplayName(m))
+			}
+		}
+	}
+	return classes
+}
+
+// resolveObjectClass walks back from m.Lines[idx] to the class of the object last put in reg:
+// a new-instance, a kotlin object INSTANCE, a field or a param of a concrete type
+func resolveObjectClass(m smaliMethodBlock, idx int, reg string) (string, bool) {
+	for i := idx - 1; i >= 0; i-- {
+		line := m.Lines[i]
+		if ni := newInstancePattern.FindStringSubmatch(line); ni != nil && ni[1] == reg {
+			return ni[2], true
+		}
+		if g := getObjectPattern.FindStringSubmatch(line); g != nil && g[1] == reg {
+			return g[2], g[2] != "Lokhttp3/Interceptor;"
+		}
+		if mo := moveObjectPattern.FindStringSubmatch(line); mo != nil && mo[1] == reg {
+			reg = mo[2]
+			continue
+		}
+		if writesRegister(line, reg) {
+			return "", false
+		}
+	}
+	// untouched since entry, a param
+	for i, r := range m.paramRegisters() {
+		if r != reg {
+			continue
+		}
+		params, _ := parseDescriptorParams(m.ParamsSig)
+		if t := params[i].String(); t != "Lokhttp3/Interceptor;" {
+			return t, true
+		}
+	}
+	return "", false
+}
+
+// applyInterceptors adds the security definitions and global parameters of every interceptor
+// to the spec, and the operations of an interface get those of its client
+func applyInterceptors(spec *swagger.Swagger) {
+	var classes []string
+	for class := range interceptorParams {
+		classes = append(classes, class)
+	}
+	sort.Strings(classes)
+	for _, class := range classes {
+		for _, p := range interceptorParams[class] {
+			if p.Scheme != "" {
+				if spec.SecurityDefinitions == nil {
+					spec.SecurityDefinitions = swagger.SecurityDefinitions{}
+				}
+				spec.SecurityDefinitions[p.securityName()] = p.securityScheme()
+				continue
+			}
+			if spec.Parameters == nil {
+				spec.Parameters = map[string]swagger.Parameter{}
+			}
+			param := p.parameter()
+			if existing, ok := spec.Parameters[p.parameterName()]; ok {
+				param = mergeParamValues(existing, param)
+			}
+			spec.Parameters[p.parameterName()] = param
+		}
+	}
+}
+
+// mergeParamValues combines the values two interceptors set for the same param, e.g. X-Platform
+// set to "android" by one and "android-tv" by another. A value computed at runtime lifts the enum.
+func mergeParamValues(a, b swagger.Parameter) swagger.Parameter {
+	if len(a.Enum) == 0 || len(b.Enum) == 0 {
+		a.Default = nil
+		a.Enum = nil
+		return a
+	}
+	for _, v := range b.Enum {
+		found := false
+		for _, e := range a.Enum {
+			if e == v {
+				found = true
+			}
+		}
+		if !found {
+			a.Enum = append(a.Enum, v)
+		}
+	}
+	return a
+}
+
+// applyClientParams adds the credentials and params the interceptors of the interface's client add
+func applyClientParams(operation *swagger.Operation, endpoint *APIEndpoint) {
+	requirement := map[string][]string{}
+	for _, class := range interfaceInterceptors[endpoint.ClassName] {
+		for _, p := range interceptorParams[class] {
+			if p.Scheme != "" {
+				requirement[p.securityName()] = []string{}
+				continue
+			}
+			ref := "#/parameters/" + p.parameterName()
+			if hasParam(operation.Parameters, p.Name, p.In) || hasParamRef(operation.Parameters, ref) {
+				// declared by the method, or added by another interceptor of the client
+				continue
+			}
+			operation.Parameters = append(operation.Parameters, *swagger.ParamRef(ref))
+		}
+	}
+	if len(requirement) > 0 {
+		// all of them are sent together
+		operation.Security = append(operation.Security, requirement)
+	}
+}
+
+// hasParam reports whether an operation already declares a param
+func hasParam(params []swagger.Parameter, name, in string) bool {
+	for _, p := range params {
+		if p.In == in && strings.EqualFold(p.Name, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasParamRef reports whether an operation already refers to a global parameter
+func hasParamRef(params []swagger.Parameter, ref string) bool {
+	for _, p := range params {
+		if p.Ref.String() == ref {
+			return true
+		}
+	}
+	return false
+}
+
+// securityName names the security definition, e.g. bearerAuth or header_X-Api-Key
+func (p interceptedParam) securityName() string {
+	switch p.Scheme {
+	case "bearer":
+		return "bearerAuth"
+	case "basic":
+		return "basicAuth"
+	}
+	return p.In + "_" + p.Name
+}
+
+func (p interceptedParam) securityScheme() *swagger.SecurityScheme {
+	switch p.Scheme {
+	case "basic":
+		return swagger.BasicAuth()
+	case "bearer":
+		// swagger 2.0 has no bearer scheme, the token goes in an apiKey header
+		s := swagger.APIKeyAuth(p.Name, "header")
+		s.Description = "Bearer token, sent as `Authorization: Bearer <token>`"
+		return s
+	}
+	s := swagger.APIKeyAuth(p.Name, p.In)
+	switch {
+	case p.Value != "" && showSecrets:
+		s.Description = "The app sends the constant `" + p.Value + "`"
+	case p.Value != "":
+		s.Description = "The app sends a constant key (redacted)"
+	}
+	return s
+}
+
+// parameterName is the key of the global parameter, e.g. header-X-Platform
+func (p interceptedParam) parameterName() string {
+	return p.In + "-" + p.Name
+}
+
+func (p interceptedParam) parameter() swagger.Parameter {
+	param := swagger.HeaderParam(p.Name)
+	if p.In == "query" {
+		param = swagger.QueryParam(p.Name)
+	}
+	param.Type = "string"
+	param.Required = true
+	param.Description = "Added to every request by an OkHttp interceptor"
+	if p.Value != "" {
+		param.Default = p.Value
+		param.Enum = []interface{}{p.Value}
+	}
+	return *param
+}
@@ -0,0 +1,191 @@
+package parser
+
+import (
+	"os"
+	"testing"
+
+	swagger "github.com/go-openapi/spec"
+)
+
+func TestInterceptors(t *testing.T) {
+	isolateGlobals(t)
+	classToFilePath["Lcom/example/di/FeatureModule;"] = "testdata/FeatureModule.smali"
+	classToFilePath["Lcom/example/di/AuthClient;"] = "testdata/AuthClient.smali"
+	if err := ScanBaseURLs([]string{"testdata/FeatureModule_ProvideFeaturesApiFactory.smali"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := ScanInterceptors([]string{"testdata/AuthInterceptor.smali", "testdata/HeadersInterceptor.smali"}); err != nil {
+		t.Fatal(err)
+	}
+
+	api := "Luk/co/goptions/libs/cloudlib/featureservice/interfaces/FeaturesApi;"
+	if got := interfaceInterceptors[api]; len(got) != 2 ||
+		got[0] != "Lcom/example/net/AuthInterceptor;" || got[1] != "Lcom/example/net/HeadersInterceptor;" {
+		t.Errorf("Expected the interceptors of the injected client, got %v", got)
+	}
+
+	data, err := os.ReadFile("testdata/FeaturesApi.smali")
+	if err != nil {
+		t.Fatal(err)
+	}
+	apis, err := ExtractAPIEndpoints(string(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	spec, err := GenerateSwaggerSpec(apis)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bearer, ok := spec.SecurityDefinitions["bearerAuth"]
+	if !ok || bearer.Type != "apiKey" || bearer.In != "header" || bearer.Name != "Authorization" {
+		t.Errorf("Expected a bearer token definition, got %+v", bearer)
+	}
+	if key, ok := spec.SecurityDefinitions["header_X-Api-Key"]; !ok || key.In != "header" {
+		t.Errorf("Expected an api key header definition, got %+v", key)
+	}
+	key, ok := spec.SecurityDefinitions["query_api_key"]
+	if !ok || key.In != "query" || key.Description != "The app sends a constant key (redacted)" {
+		t.Errorf("Expected an api key query definition noting its redacted constant, got %+v", key)
+	}
+	platform, ok := spec.Parameters["header-X-Platform"]
+	if !ok || platform.Default != "android" || !platform.Required {
+		t.Errorf("Expected a global X-Platform header, got %+v", platform)
+	}
+	if lang, ok := spec.Parameters["query-lang"]; !ok || lang.Default != nil {
+		t.Errorf("Expected a global lang query param without a value, got %+v", lang)
+	}
+
+	get := spec.Paths.Paths["/featureservice/v1"].Get
+	if len(get.Security) != 1 || len(get.Security[0]) != 3 {
+		t.Errorf("Expected one requirement with the three credentials, got %v", get.Security)
+	}
+	refs := 0
+	for _, p := range get.Parameters {
+		if p.Ref.String() == "#/parameters/header-X-Platform" || p.Ref.String() == "#/parameters/query-lang" {
+			refs++
+		}
+	}
+	if refs != 2 {
+		t.Errorf("Expected the operation to reference the global params, got %+v", get.Parameters)
+	}
+}
+
+const credentialsInterceptorSmali = `.class public final Lcom/example/net/CredentialsInterceptor;
+.super Ljava/lang/Object;
+
+# interfaces
+.implements Lokhttp3/Interceptor;
+
+# virtual methods
+.method public final addSession(Lokhttp3/Request$Builder;Ljava/lang/String;)V
+    .locals 3
+
+    const-string v0, "bearer token cache miss"
+
+    invoke-static {v0}, Lcom/example/Log;->d(Ljava/lang/String;)V
+
+    const-string v0, "Authorization"
+
+    invoke-virtual {p1, v0, p2}, Lokhttp3/Request$Builder;->header(Ljava/lang/String;Ljava/lang/String;)Lokhttp3/Request$Builder;
+
+    return-void
+.end method
+
+.method public final addBearer(Lokhttp3/Request$Builder;Ljava/lang/String;)V
+    .locals 3
+
+    new-instance v0, Ljava/lang/StringBuilder;
+
+    const-string v1, "Bearer "
+
+    invoke-direct {v0, v1}, Ljava/lang/StringBuilder;-><init>(Ljava/lang/String;)V
+
+    invoke-virtual {v0, p2}, Ljava/lang/StringBuilder;->append(Ljava/lang/String;)Ljava/lang/StringBuilder;
+
+    invoke-virtual {v0}, Ljava/lang/StringBuilder;->toString()Ljava/lang/String;
+
+    move-result-object v0
+
+    const-string v1, "Authorization"
+
+    invoke-virtual {p1, v1, v0}, Lokhttp3/Request$Builder;->header(Ljava/lang/String;Ljava/lang/String;)Lokhttp3/Request$Builder;
+
+    return-void
+.end method
+
+.method public final addBasic(Lokhttp3/Request$Builder;Ljava/lang/String;Ljava/lang/String;)V
+    .locals 2
+
+    invoke-static {p2, p3}, Lokhttp3/Credentials;->basic(Ljava/lang/String;Ljava/lang/String;)Ljava/lang/String;
+
+    move-result-object v0
+
+    const-string v1, "Authorization"
+
+    invoke-virtual {p1, v1, v0}, Lokhttp3/Request$Builder;->header(Ljava/lang/String;Ljava/lang/String;)Lokhttp3/Request$Builder;
+
+    return-void
+.end method
+`
+
+func TestCredentialScheme(t *testing.T) {
+	want := map[string]string{"addSession": "apiKey", "addBearer": "bearer", "addBasic": "basic"}
+	for _, m := range parseMethodBlocks(credentialsInterceptorSmali) {
+		params := interceptedParams(m)
+		if len(params) != 1 || params[0].Scheme != want[m.Name] {
+			t.Errorf("%s: expected an Authorization header with scheme %q, got %+v", m.Name, want[m.Name], params)
+		}
+	}
+}
+
+func TestClientParamsDeduplicated(t *testing.T) {
+	isolateGlobals(t)
+	interceptorParams = map[string][]interceptedParam{
+		"Lcom/example/net/PlatformInterceptor;": {{In: "header", Name: "X-Platform", Value: "android"}},
+		"Lcom/example/net/HeadersInterceptor;":  {{In: "header", Name: "X-Platform", Value: "android"}, {In: "query", Name: "lang"}},
+	}
+	interfaceInterceptors = map[string][]string{
+		"Lcom/example/Api;": {"Lcom/example/net/PlatformInterceptor;", "Lcom/example/net/HeadersInterceptor;"},
+	}
+
+	operation := &swagger.Operation{}
+	applyClientParams(operation, &APIEndpoint{ClassName: "Lcom/example/Api;"})
+	if len(operation.Parameters) != 2 ||
+		operation.Parameters[0].Ref.String() != "#/parameters/header-X-Platform" ||
+		operation.Parameters[1].Ref.String() != "#/parameters/query-lang" {
+		t.Errorf("Expected one reference per global param, got %+v", operation.Parameters)
+	}
+}
+
+func TestInterceptorParamsMerged(t *testing.T) {
+	isolateGlobals(t)
+	interceptorParams = map[string][]interceptedParam{
+		"Lcom/example/net/PlatformInterceptor;": {
+			{In: "header", Name: "X-Platform", Value: "android"},
+			{In: "header", Name: "token", Value: "h3ad3r", Scheme: "apiKey"},
+		},
+		"Lcom/example/net/TvInterceptor;": {
+			{In: "header", Name: "X-Platform", Value: "android-tv"},
+			{In: "query", Name: "token", Value: "qu3ry", Scheme: "apiKey"},
+		},
+	}
+
+	spec := &swagger.Swagger{}
+	applyInterceptors(spec)
+	if platform := spec.Parameters["header-X-Platform"]; len(platform.Enum) != 2 ||
+		platform.Enum[0] != "android" || platform.Enum[1] != "android-tv" {
+		t.Errorf("Expected the values of both interceptors, got %+v", platform.Enum)
+	}
+	header, query := spec.SecurityDefinitions["header_token"], spec.SecurityDefinitions["query_token"]
+	if header == nil || header.In != "header" || query == nil || query.In != "query" {
+		t.Errorf("Expected a definition per location, got %+v", spec.SecurityDefinitions)
+	}
+
+	ShowSecrets(true)
+	spec = &swagger.Swagger{}
+	applyInterceptors(spec)
+	if d := spec.SecurityDefinitions["query_token"].Description; d != "The app sends the constant `qu3ry`" {
+		t.Errorf("Expected the constant key when asked for, got %q", d)
+	}
+}
@@ -0,0 +1,482 @@
+package parser
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// --------------------------------------------------------------------------
+// kotlin.Metadata decoding
+//
+// Kotlin classes carry `@kotlin.Metadata(k, d1, d2, ...)`. d1 is a protobuf
+// message (kotlin/metadata/metadata.proto) packed into strings, d2 is the string
+// table the message indexes into. We only decode what the spec needs: names and
+// nullability of properties, constructor and function parameters.
+// --------------------------------------------------------------------------
+
+// kotlin.Metadata kind of a regular class (as opposed to file facades, lambdas, ...)
+const kotlinClassKind = "0x1"
+
+// kotlinClassMetadata is the decoded part of a class' kotlin.Metadata
+type kotlinClassMetadata struct {
+	Properties         []kotlinValue    // in metadata order
+	PrimaryConstructor []kotlinValue    // parameters, in declaration order
+	Functions          []kotlinFunction // in metadata order
+}
+
+// kotlinValue is a named and typed value: a property or a parameter
+type kotlinValue struct {
+	Name     string
+	Nullable bool
+}
+
+type kotlinFunction struct {
+	Name   string
+	Params []kotlinValue
+}
+
+// property returns the property with the given name
+func (m *kotlinClassMetadata) property(name string) (kotlinValue, bool) {
+	for _, p := range m.Properties {
+		if p.Name == name {
+			return p, true
+		}
+	}
+	return kotlinValue{}, false
+}
+
+// function returns the function with the given name and parameter count
+func (m *kotlinClassMetadata) function(name string, params int) (kotlinFunction, bool) {
+	for _, f := range m.Functions {
+		if f.Name == name && len(f.Params) == params {
+			return f, true
+		}
+	}
+	return kotlinFunction{}, false
+}
+
+// propertyOrder returns property names in declaration order: primary constructor
+// parameters first (metadata sorts properties by name), then the remaining properties
+func (m *kotlinClassMetadata) propertyOrder() []string {
+	var order []string
+	seen := map[string]bool{}
+	for _, p := range m.PrimaryConstructor {
+		if _, ok := m.property(p.Name); ok && !seen[p.Name] {
+			order = append(order, p.Name)
+			seen[p.Name] = true
+		}
+	}
+	for _, p := range m.Properties {
+		if !seen[p.Name] {
+			order = append(order, p.Name)
+			seen[p.Name] = true
+		}
+	}
+	return order
+}
+
+// parseKotlinMetadata decodes the kotlin.Metadata annotation of a class, nil when absent
+func parseKotlinMetadata(annotations []SmaliAnnotation) (*kotlinClassMetadata, error) {
+	a, ok := findAnnotation(annotations, "Lkotlin/Metadata;")
+	if !ok {
+		return nil, nil
+	}
+	if k := strings.TrimSpace(a.Elements["k"]); k != kotlinClassKind {
+		return nil, nil
+	}
+	d1 := a.Strings("d1")
+	d2 := a.Strings("d2")
+	if len(d1) == 0 {
+		return nil, errors.New("kotlin.Metadata without d1")
+	}
+	return decodeKotlinClass(decodeKotlinBytes(d1), d2)
+}
+
+// decodeKotlinBytes turns d1 back into protobuf bytes.
+// Since Kotlin 1.1 strings start with a \u0000 marker and hold one byte per char,
+// older compilers pack 7 bits per char.
+func decodeKotlinBytes(d1 []string) []byte {
+	data := strings.Join(d1, "")
+	if strings.HasPrefix(data, "\u0000") {
+		return charsToBytes(data[1:])
+	}
+	data = strings.TrimPrefix(data, "\uffff")
+	bytes := charsToBytes(data)
+	for i := range bytes {
+		bytes[i] = (bytes[i] + 0x7f) & 0x7f
+	}
+	return decode7to8(bytes)
+}
+
+func charsToBytes(s string) []byte {
+	var bytes []byte
+	for _, r := range s {
+		bytes = append(bytes, byte(r))
+	}
+	return bytes
+}
+
+func decode7to8(data []byte) []byte {
+	resultLength := 7 * len(data) / 8
+	result := make([]byte, resultLength)
+	byteIndex, bit := 0, 0
+	for i := 0; i < resultLength && byteIndex+1 < len(data); i++ {
+		firstPart := int(data[byteIndex]) >> bit
+		byteIndex++
+		secondPart := int(data[byteIndex]) & ((1 << (bit + 1)) - 1)
+		result[i] = byte(firstPart + (secondPart << (7 - bit)))
+		if bit == 6 {
+			byteIndex++
+			bit = 0
+		} else {
+			bit++
+		}
+	}
+	return result
+}
+
+// decodeKotlinClass reads JvmProtoBuf.StringTableTypes (length delimited) followed by ProtoBuf.Class
+func decodeKotlinClass(data []byte, strs []string) (*kotlinClassMetadata, error) {
+	r := &protoReader{buf: data}
+	tableBytes, err := r.bytes()
+	if err != nil {
+		return nil, fmt.Errorf("string table: %w", err)
+	}
+	names, err := decodeNameResolver(tableBytes, strs)
+	if err != nil {
+		return nil, fmt.Errorf("string table: %w", err)
+	}
+
+	meta := &kotlinClassMetadata{}
+	err = forEachProtoField(data[r.pos:], func(field, wire int, r *protoReader) error {
+		switch field {
+		case 8: // constructor
+			b, err := r.bytes()
+			if err != nil {
+				return err
+			}
+			ctor, secondary, err := decodeKotlinConstructor(b, names)
+			if err != nil {
+				return err
+			}
+			if !secondary && meta.PrimaryConstructor == nil {
+				meta.PrimaryConstructor = ctor
+			}
+		case 9: // function
+			b, err := r.bytes()
+			if err != nil {
+				return err
+			}
+			fn, err := decodeKotlinFunction(b, names)
+			if err != nil {
+				return err
+			}
+			meta.Functions = append(meta.Functions, fn)
+		case 10: // property
+			b, err := r.bytes()
+			if err != nil {
+				return err
+			}
+			prop, err := decodeKotlinNamedValue(b, names)
+			if err != nil {
+				return err
+			}
+			meta.Properties = append(meta.Properties, prop)
+		default:
+			return r.skip(wire)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return meta, nil
+}
+
+// Constructor flags: hasAnnotations (1 bit), visibility (3 bits), isSecondary (1 bit)
+const kotlinConstructorSecondaryFlag = 1 << 4
+
+func decodeKotlinConstructor(data []byte, names *kotlinNameResolver) ([]kotlinValue, bool, error) {
+	flags := uint64(6)
+	params := []kotlinValue{}
+	err := forEachProtoField(data, func(field, wire int, r *protoReader) error {
+		switch field {
+		case 1:
+			v, err := r.varint()
+			flags = v
+			return err
+		case 2:
+			b, err := r.bytes()
+			if err != nil {
+				return err
+			}
+			p, err := decodeKotlinNamedValue(b, names)
+			params = append(params, p)
+			return err
+		default:
+			return r.skip(wire)
+		}
+	})
+	return params, flags&kotlinConstructorSecondaryFlag != 0, err
+}
+
+func decodeKotlinFunction(data []byte, names *kotlinNameResolver) (kotlinFunction, error) {
+	var fn kotlinFunction
+	err := forEachProtoField(data, func(field, wire int, r *protoReader) error {
+		switch field {
+		case 2:
+			v, err := r.varint()
+			fn.Name = names.get(int(v))
+			return err
+		case 6:
+			b, err := r.bytes()
+			if err != nil {
+				return err
+			}
+			p, err := decodeKotlinNamedValue(b, names)
+			fn.Params = append(fn.Params, p)
+			return err
+		default:
+			return r.skip(wire)
+		}
+	})
+	return fn, err
+}
+
+// decodeKotlinNamedValue reads a Property or ValueParameter, both keep
+// the name index in field 2 and the Type in field 3
+func decodeKotlinNamedValue(data []byte, names *kotlinNameResolver) (kotlinValue, error) {
+	var v kotlinValue
+	err := forEachProtoField(data, func(field, wire int, r *protoReader) error {
+		switch field {
+		case 2:
+			idx, err := r.varint()
+			v.Name = names.get(int(idx))
+			return err
+		case 3:
+			b, err := r.bytes()
+			if err != nil {
+				return err
+			}
+			v.Nullable, err = decodeKotlinTypeNullable(b)
+			return err
+		default:
+			return r.skip(wire)
+		}
+	})
+	return v, err
+}
+
+func decodeKotlinTypeNullable(data []byte) (bool, error) {
+	nullable := false
+	err := forEachProtoField(data, func(field, wire int, r *protoReader) error {
+		if field == 3 {
+			v, err := r.varint()
+			nullable = v != 0
+			return err
+		}
+		return r.skip(wire)
+	})
+	return nullable, err
+}
+
+// --------------------------------------------------------------------------
+// JvmNameResolver: maps name indexes to strings from d2
+// --------------------------------------------------------------------------
+
+type kotlinStringRecord struct {
+	str         *string
+	predefined  bool
+	operation   uint64
+	substring   []uint64
+	replaceChar []uint64
+}
+
+type kotlinNameResolver struct {
+	records []kotlinStringRecord // expanded by range, one per string index
+	strings []string
+}
+
+func decodeNameResolver(data []byte, strs []string) (*kotlinNameResolver, error) {
+	resolver := &kotlinNameResolver{strings: strs}
+	err := forEachProtoField(data, func(field, wire int, r *protoReader) error {
+		if field != 1 {
+			return r.skip(wire)
+		}
+		b, err := r.bytes()
+		if err != nil {
+			return err
+		}
+		rangeCount := uint64(1)
+		var record kotlinStringRecord
+		err = forEachProtoField(b, func(field, wire int, r *protoReader) error {
+			var err error
+			switch field {
+			case 1:
+				rangeCount, err = r.varint()
+			case 2:
+				_, err = r.varint()
+				record.predefined = true
+			case 3:
+				record.operation, err = r.varint()
+			case 4:
+				record.substring, err = r.packedVarints(wire, record.substring)
+			case 5:
+				record.replaceChar, err = r.packedVarints(wire, record.replaceChar)
+			case 6:
+				var s []byte
+				s, err = r.bytes()
+				str := string(s)
+				record.str = &str
+			default:
+				err = r.skip(wire)
+			}
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		for i := uint64(0); i < rangeCount; i++ {
+			resolver.records = append(resolver.records, record)
+		}
+		return nil
+	})
+	return resolver, err
+}
+
+func (n *kotlinNameResolver) get(idx int) string {
+	if idx >= len(n.records) {
+		if idx < len(n.strings) {
+			return n.strings[idx]
+		}
+		return ""
+	}
+	record := n.records[idx]
+	var s string
+	switch {
+	case record.str != nil:
+		s = *record.str
+	case record.predefined:
+		// predefined strings are kotlin builtin class names, never member names
+		return ""
+	case idx < len(n.strings):
+		s = n.strings[idx]
+	}
+	if len(record.substring) >= 2 {
+		begin, end := int(record.substring[0]), int(record.substring[1])
+		if 0 <= begin && begin <= end && end <= len(s) {
+			s = s[begin:end]
+		}
+	}
+	if len(record.replaceChar) >= 2 {
+		s = strings.ReplaceAll(s, string(rune(record.replaceChar[0])), string(rune(record.replaceChar[1])))
+	}
+	switch record.operation {
+	case 1: // INTERNAL_TO_CLASS_ID
+		s = strings.ReplaceAll(s, "$", ".")
+	case 2: // DESC_TO_CLASS_ID
+		if len(s) >= 2 {
+			s = s[1 : len(s)-1]
+		}
+		s = strings.ReplaceAll(s, "$", ".")
+	}
+	return s
+}
+
+// --------------------------------------------------------------------------
+// minimal protobuf wire format reader
+// --------------------------------------------------------------------------
+
+type protoReader struct {
+	buf []byte
+	pos int
+}
+
+var errProtoTruncated = errors.New("truncated protobuf message")
+
+func (r *protoReader) varint() (uint64, error) {
+	var v uint64
+	for shift := uint(0); shift < 64; shift += 7 {
+		if r.pos >= len(r.buf) {
+			return 0, errProtoTruncated
+		}
+		b := r.buf[r.pos]
+		r.pos++
+		v |= uint64(b&0x7f) << shift
+		if b < 0x80 {
+			return v, nil
+		}
+	}
+	return 0, errors.New("malformed varint")
+}
+
+func (r *protoReader) bytes() ([]byte, error) {
+	n, err := r.varint()
+	if err != nil {
+		return nil, err
+	}
+	if uint64(len(r.buf)-r.pos) < n {
+		return nil, errProtoTruncated
+	}
+	b := r.buf[r.pos : r.pos+int(n)]
+	r.pos += int(n)
+	return b, nil
+}
+
+// packedVarints appends a repeated varint field, either packed or not
+func (r *protoReader) packedVarints(wire int, values []uint64) ([]uint64, error) {
+	if wire == 0 {
+		v, err := r.varint()
+		return append(values, v), err
+	}
+	b, err := r.bytes()
+	if err != nil {
+		return values, err
+	}
+	packed := &protoReader{buf: b}
+	for packed.pos < len(b) {
+		v, err := packed.varint()
+		if err != nil {
+			return values, err
+		}
+		values = append(values, v)
+	}
+	return values, nil
+}
+
+func (r *protoReader) skip(wire int) error {
+	switch wire {
+	case 0:
+		_, err := r.varint()
+		return err
+	case 1:
+		r.pos += 8
+	case 2:
+		_, err := r.bytes()
+		return err
+	case 5:
+		r.pos += 4
+	default:
+		return fmt.Errorf("unsupported protobuf wire type %d", wire)
+	}
+	if r.pos > len(r.buf) {
+		return errProtoTruncated
+	}
+	return nil
+}
+
+// forEachProtoField calls fn for each field of the message, fn must consume the value
+func forEachProtoField(data []byte, fn func(field, wire int, r *protoReader) error) error {
+	r := &protoReader{buf: data}
+	for r.pos < len(data) {
+		tag, err := r.varint()
+		if err != nil {
+			return err
+		}
+		if err := fn(int(tag>>3), int(tag&7), r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+package parser
+
+import (
+	_ "embed"
+	"testing"
+
+	swagger "github.com/go-openapi/spec"
+)
+
+//go:embed testdata/FeatureStatus.smali
+var featureStatusSmali string
+
+func TestDecodeKotlinFunctions(t *testing.T) {
+	meta := parseSmaliClass(featuresApiSmali).Kotlin
+	if meta == nil {
+		t.Fatal("Expected kotlin metadata")
+	}
+	fn, ok := meta.function("getFeature", 2)
+	if !ok {
+		t.Fatal("Expected function getFeature")
+	}
+	if fn.Params[0].Name != "systemId" || fn.Params[1].Name != "featureName" {
+		t.Errorf("Unexpected params %+v", fn.Params)
+	}
+}
+
+func TestDecodeKotlinProperties(t *testing.T) {
+	meta := parseSmaliClass(featureStatusSmali).Kotlin
+	if meta == nil {
+		t.Fatal("Expected kotlin metadata")
+	}
+	order := meta.propertyOrder()
+	if len(order) != 3 || order[0] != "name" || order[1] != "supported" || order[2] != "enabled" {
+		t.Errorf("Expected declaration order name, supported, enabled, got %v", order)
+	}
+	for _, p := range meta.Properties {
+		if !p.Nullable {
+			t.Errorf("Expected %s to be nullable", p.Name)
+		}
+	}
+}
+
+func TestKotlinModelSchema(t *testing.T) {
+	isolateGlobals(t)
+	classToFilePath["Luk/co/goptions/libs/cloudlib/featureservice/models/FeatureStatus;"] = "testdata/FeatureStatus.smali"
+	spec := &swagger.Swagger{SwaggerProps: swagger.SwaggerProps{Definitions: map[string]swagger.Schema{}}}
+	if _, err := interpretTypeAndBuildDefinition("Luk/co/goptions/libs/cloudlib/featureservice/models/FeatureStatus;", spec); err != nil {
+		t.Fatal(err)
+	}
+
+	def := spec.Definitions["FeatureStatus"]
+	if len(def.Required) != 0 {
+		t.Errorf("Expected no required properties, got %v", def.Required)
+	}
+	items := def.Properties.ToOrderedSchemaItems()
+	if len(items) != 3 || items[0].Name != "name" || items[2].Name != "enabled" {
+		t.Errorf("Expected properties in declaration order, got %v", items)
+	}
+	if nullable, _ := items[0].Extensions.GetBool("x-nullable"); !nullable {
+		t.Errorf("Expected name to be x-nullable")
+	}
+}
+
+const namedParamsApiSmali = `.class public interface abstract Lcom/example/NamedApi;
+.super Ljava/lang/Object;
+
+# virtual methods
+.method public abstract search(JLjava/lang/String;)Lretrofit2/Call;
+    .param p3, "flag"    # Ljava/lang/String;
+        .annotation runtime Lretrofit2/http/QueryName;
+        .end annotation
+    .end param
+    .annotation runtime Lretrofit2/http/GET;
+        value = "search"
+    .end annotation
+.end method
+`
+
+func TestParamNamesFromDebugInfo(t *testing.T) {
+	methods := fillRetrofitAnnotations(parseSmaliMethods(namedParamsApiSmali))
+	if len(methods[0].Params) != 1 || methods[0].Params[0].displayName() != "flag" {
+		t.Errorf("Expected param named flag, got %+v", methods[0].Params)
+	}
+	if i := paramIndex(methods[0].ParamsSig, "p3"); i != 1 {
+		t.Errorf("Expected p3 to be the second param after a long, got %d", i)
+	}
+}
+
+func TestRefPropertyExtensions(t *testing.T) {
+	extensions := swagger.Extensions{}
+	extensions.Add("x-nullable", true)
+	extensions.Add("x-order", float64(1))
+
+	ref, _ := refSchema("Device")
+	wrapped := withExtensions(*ref, extensions)
+	if wrapped.Ref.String() != "" || len(wrapped.AllOf) != 1 || wrapped.AllOf[0].Ref.String() != "#/definitions/Device" {
+		t.Errorf("Expected the reference to be wrapped in an allOf, got %+v", wrapped)
+	}
+	if nullable, _ := wrapped.Extensions.GetBool("x-nullable"); !nullable || wrapped.Extensions["x-order"] != float64(1) {
+		t.Errorf("Expected the extensions on the wrapper, got %v", wrapped.Extensions)
+	}
+	if inline := withExtensions(*primitiveSchema("string"), extensions); len(inline.AllOf) != 0 || inline.Extensions["x-order"] != float64(1) {
+		t.Errorf("Expected an inline schema to carry the extensions itself, got %+v", inline)
+	}
+}
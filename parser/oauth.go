@@ -0,0 +1,454 @@
+package parser
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	swagger "github.com/go-openapi/spec"
+)
+
+// --------------------------------------------------------------------------
+// OAuth2, from AppAuth configurations, token endpoints posting a grant_type
+// form field and scope constants
+// --------------------------------------------------------------------------
+
+// Regex for the names of scope constants, SCOPE or SCOPES as a word of an upper case name,
+// e.g. SCOPE, SCOPE_READ, DEFAULT_SCOPES or OAUTH_SCOPE but not SCOPED_STORAGE
+var scopeConstantPattern = regexp.MustCompile(`^(?:[A-Z0-9]+_)*SCOPES?(?:_[A-Z0-9]+)*$`)
+
+// Regex for a string stored into an array, e.g. `aput-object v2, v0, v1`
+var aputObjectPattern = regexp.MustCompile(`^aput-object\s+([vp]\d+),\s+([vp]\d+),\s+[vp]\d+$`)
+
+// oauthFlow is an OAuth2 flow found in the app
+type oauthFlow struct {
+	Flow             string // swagger flow: accessCode, password or application
+	AuthorizationURL string
+	TokenURL         string
+	ClientIDs        []string
+	Scopes           []oauthScope // scopes requested with this flow
+	Source           string       // e.g. "AppAuth in LoginActivity.startLogin" or "AuthApi.token"
+}
+
+// oauthScope is a scope string found in the app
+type oauthScope struct {
+	Name   string // e.g. "devices:read"
+	Source string // e.g. "AuthConfig.SCOPE_READ" or "call to AuthApi.token in TokenRepository.login"
+}
+
+// Found by ScanOAuth
+var (
+	oauthFlows    []oauthFlow
+	openIDIssuers []string // issuers handed to AppAuth's fetchFromIssuer
+)
+
+// tokenEndpoint is a retrofit method posting a grant_type form field
+type tokenEndpoint struct {
+	Endpoint *APIEndpoint
+	Method   string        // name in the smali, e.g. "token"
+	Grants   []*tokenGrant // what its callers pass, by grant type
+}
+
+// tokenGrant is what the callers of a token endpoint pass with one grant_type
+type tokenGrant struct {
+	GrantType string // e.g. "password", empty when no caller passes a constant
+	ClientIDs []string
+	Scopes    []oauthScope
+}
+
+// oauthScan is the state of ScanOAuth
+type oauthScan struct {
+	tokens         []*tokenEndpoint
+	scopeConstants map[string]string // scope => constant holding it, e.g. "profile" => "AuthConfig.SCOPE_PROFILE"
+	appAuthFlows   []oauthFlow
+	appAuthClients []string     // client IDs of AppAuth authorization requests
+	appAuthScopes  []oauthScope // scopes of AppAuth authorization requests
+}
+
+// ScanOAuth looks for OAuth2 flows: AppAuth AuthorizationServiceConfiguration (authorization code),
+// retrofit token endpoints and the grant types their callers pass, and the scopes each flow requests.
+// It runs after ScanBaseURLs, which gives the base URL of the token endpoints.
+func ScanOAuth(files []string) error {
+	log.Printf("Scanning %d smali files for OAuth2 flows...", len(files))
+	oauthFlows, openIDIssuers = nil, nil
+	scan := &oauthScan{scopeConstants: map[string]string{}}
+
+	// token endpoints and scope constants first, the calls are followed once they are all known.
+	// Only the paths of the AppAuth users are kept, the callers are read again rather than held in memory.
+	appAuthUsers := map[string]bool{}
+	for _, path := range files {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			log.Printf("Could not read %s: %v", path, err)
+			continue
+		}
+		content := string(data)
+		if strings.Contains(content, "Lnet/openid/appauth/") {
+			appAuthUsers[path] = true
+		}
+		if strings.Contains(content, `"grant_type"`) {
+			scan.addTokenEndpoints(content)
+		}
+		if strings.Contains(content, "SCOPE") {
+			scan.addScopeConstants(content)
+		}
+	}
+
+	for _, path := range files {
+		if !appAuthUsers[path] && len(scan.tokens) == 0 {
+			continue
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		content := string(data)
+		if !appAuthUsers[path] && !scan.callsToken(content) {
+			continue
+		}
+		for _, m := range parseMethodBlocks(content) {
+			scan.followCalls(m)
+		}
+	}
+
+	for _, f := range scan.appAuthFlows {
+		f.ClientIDs, f.Scopes = scan.appAuthClients, scan.appAuthScopes
+		oauthFlows = append(oauthFlows, f)
+	}
+	for _, t := range scan.tokens {
+		oauthFlows = append(oauthFlows, t.flows()...)
+	}
+	scan.reportUnusedScopeConstants()
+	log.Printf("Found %d OAuth2 flows", len(oauthFlows))
+	return nil
+}
+
+// addTokenEndpoints adds the retrofit methods of a class posting a grant_type form field
+func (s *oauthScan) addTokenEndpoints(content string) {
+	apis, err := ExtractAPIEndpoints(content)
+	if err != nil {
+		return
+	}
+	for _, api := range apis {
+		if _, ok := formField(api, "grant_type"); !ok {
+			continue
+		}
+		method := api.MethodName
+		if api.ObfuscatedName != "" {
+			method = api.ObfuscatedName
+		}
+		s.tokens = append(s.tokens, &tokenEndpoint{Endpoint: api, Method: method})
+	}
+}
+
+// addScopeConstants remembers the static final String scope constants of a class, to name the scopes flows request
+func (s *oauthScan) addScopeConstants(content string) {
+	class := ""
+	for _, f := range parseSmaliFields(content) {
+		if !f.hasModifier("static") || !f.hasModifier("final") || f.TypeSig != "Ljava/lang/String;" ||
+			!scopeConstantPattern.MatchString(f.Name) || !strings.HasPrefix(f.Value, `"`) {
+			continue
+		}
+		if class == "" {
+			class = typeShortName(parseSmaliClass(content).Name)
+		}
+		for _, name := range splitScopes(unquoteSmali(f.Value)) {
+			if _, ok := s.scopeConstants[name]; !ok {
+				s.scopeConstants[name] = class + "." + f.Name
+			}
+		}
+	}
+}
+
+// reportUnusedScopeConstants reports the scope constants no flow found requests
+func (s *oauthScan) reportUnusedScopeConstants() {
+	used := map[string]bool{}
+	for _, f := range oauthFlows {
+		for _, scope := range f.Scopes {
+			used[scope.Name] = true
+		}
+	}
+	var unused []string
+	for name, constant := range s.scopeConstants {
+		if !used[name] {
+			unused = appendDistinct(unused, constant)
+		}
+	}
+	sort.Strings(unused)
+	for _, constant := range unused {
+		addDiagnostic("scope constant %s is not requested by any OAuth2 flow found, its scopes are left out", constant)
+	}
+}
+
+// callsToken reports whether a class may call one of the token endpoints
+func (s *oauthScan) callsToken(content string) bool {
+	for _, t := range s.tokens {
+		if strings.Contains(content, t.Endpoint.ClassName+"->"+t.Method+"(") {
+			return true
+		}
+	}
+	return false
+}
+
+// formField returns the @Field param of an endpoint with the given name
+func formField(api *APIEndpoint, name string) (SmaliParam, bool) {
+	for _, p := range api.Params {
+		if p.Annotation == "Field" && p.FieldVar == name {
+			return p, true
+		}
+	}
+	return SmaliParam{}, false
+}
+
+// followCalls follows the AppAuth calls of a method, and the calls to token endpoints
+// to learn the grant types, client IDs and scopes they are given
+func (s *oauthScan) followCalls(m smaliMethodBlock) {
+	resolve := func(i int, reg string) (string, bool) {
+		v, ok := resolveStringRegister(m.Lines, i, reg, 0)
+		return v.Value, ok
+	}
+	for i, line := range m.Lines {
+		call := invokePattern.FindStringSubmatch(line)
+		if call == nil {
+			continue
+		}
+		args := splitRegisters(call[1])
+		class, method := call[2], call[3]
+		switch {
+		case class == "Lnet/openid/appauth/AuthorizationServiceConfiguration;" && method == "<init>" && len(args) >= 3:
+			auth, okAuth := resolve(i, args[1])
+			token, okToken := resolve(i, args[2])
+			if !okAuth || !okToken {
+				addDiagnostic("%s: could not resolve the endpoints of an AppAuth configuration", methodDisplayName(m))
+				continue
+			}
+			s.appAuthFlows = append(s.appAuthFlows, oauthFlow{
+				Flow: "accessCode", AuthorizationURL: auth, TokenURL: token,
+				Source: "AppAuth in " + methodDisplayName(m),
+			})
+		case class == "Lnet/openid/appauth/AuthorizationServiceConfiguration;" && method == "fetchFromIssuer" && len(args) >= 1:
+			if issuer, ok := resolve(i, args[0]); ok {
+				openIDIssuers = appendDistinct(openIDIssuers, issuer)
+			}
+		case class == "Lnet/openid/appauth/AuthorizationRequest$Builder;" && method == "<init>" && len(args) >= 3:
+			if clientID, ok := resolve(i, args[2]); ok {
+				s.appAuthClients = appendDistinct(s.appAuthClients, clientID)
+			}
+		case strings.HasPrefix(class, "Lnet/openid/appauth/") && method == "setScope" && len(args) == 2:
+			if scope, ok := resolve(i, args[1]); ok {
+				s.appAuthScopes = s.addScopes(s.appAuthScopes, scope, "AppAuth request in "+methodDisplayName(m))
+			}
+		case strings.HasPrefix(class, "Lnet/openid/appauth/") && method == "setScopes" && call[4] == "[Ljava/lang/String;" && len(args) == 2:
+			for _, scope := range resolveStringArray(m.Lines, i, args[1]) {
+				s.appAuthScopes = s.addScopes(s.appAuthScopes, scope, "AppAuth request in "+methodDisplayName(m))
+			}
+		default:
+			for _, t := range s.tokens {
+				if class != t.Endpoint.ClassName || method != t.Method {
+					continue
+				}
+				value := func(field string) (string, bool) {
+					p, ok := formField(t.Endpoint, field)
+					if !ok {
+						return "", false
+					}
+					n, err := strconv.Atoi(strings.TrimPrefix(p.Register, "p"))
+					if err != nil || n >= len(args) {
+						return "", false
+					}
+					// the receiver is args[0] like p0 is this, wide args take two registers in both
+					return resolve(i, args[n])
+				}
+				grantType, _ := value("grant_type")
+				grant := t.grant(grantType)
+				if clientID, ok := value("client_id"); ok {
+					grant.ClientIDs = appendDistinct(grant.ClientIDs, clientID)
+				}
+				if scope, ok := value("scope"); ok {
+					grant.Scopes = s.addScopes(grant.Scopes, scope,
+						"call to "+typeShortName(t.Endpoint.ClassName)+"."+t.Endpoint.MethodName+" in "+methodDisplayName(m))
+				}
+			}
+		}
+	}
+}
+
+// grant returns what the callers pass to the endpoint with a grant type, adding it on first use
+func (t *tokenEndpoint) grant(grantType string) *tokenGrant {
+	for _, g := range t.Grants {
+		if g.GrantType == grantType {
+			return g
+		}
+	}
+	g := &tokenGrant{GrantType: grantType}
+	t.Grants = append(t.Grants, g)
+	return g
+}
+
+// resolveStringArray resolves the strings stored into the array in reg, walking back from lines[idx] to its new-array
+func resolveStringArray(lines []string, idx int, reg string) []string {
+	var values []string
+	for i := idx - 1; i >= 0; i-- {
+		if m := aputObjectPattern.FindStringSubmatch(lines[i]); m != nil && m[2] == reg {
+			if v, ok := resolveStringRegister(lines, i, m[1], 0); ok {
+				values = append([]string{v.Value}, values...)
+			}
+			continue
+		}
+		if writesRegister(lines[i], reg) {
+			break
+		}
+	}
+	return values
+}
+
+// flows turns the grant types passed to a token endpoint into swagger flows
+func (t *tokenEndpoint) flows() []oauthFlow {
+	tokenURL := t.Endpoint.Path
+	if b, ok := interfaceBindings[t.Endpoint.ClassName]; ok && b.BaseURL != "" {
+		tokenURL = strings.TrimSuffix(b.BaseURL, "/") + "/" + strings.TrimPrefix(tokenURL, "/")
+	} else {
+		addDiagnostic("token endpoint %s.%s has no known base URL, its tokenUrl is relative",
+			typeShortName(t.Endpoint.ClassName), t.Endpoint.MethodName)
+	}
+	source := typeShortName(t.Endpoint.ClassName) + "." + t.Endpoint.MethodName
+
+	var flows []oauthFlow
+	for _, g := range t.Grants {
+		flow := oauthFlow{TokenURL: tokenURL, ClientIDs: g.ClientIDs, Scopes: g.Scopes, Source: source}
+		grantType := g.GrantType
+		if grantType == "" {
+			// no constant passed, guess from the other fields
+			_, username := formField(t.Endpoint, "username")
+			_, password := formField(t.Endpoint, "password")
+			if !username || !password {
+				addDiagnostic("could not tell the grant type of token endpoint %s", source)
+				continue
+			}
+			grantType = "password"
+		}
+		switch grantType {
+		case "password":
+			flow.Flow = "password"
+		case "client_credentials":
+			flow.Flow = "application"
+		case "authorization_code":
+			flow.Flow = "accessCode"
+		default:
+			// refresh_token and extension grants refresh or exchange tokens of the other flows
+			continue
+		}
+		flows = append(flows, flow)
+	}
+	return flows
+}
+
+// splitScopes splits a space or comma separated scope string
+func splitScopes(value string) []string {
+	return strings.FieldsFunc(value, func(r rune) bool { return r == ' ' || r == ',' })
+}
+
+// addScopes adds the scopes of a scope string to those of a flow, named after the constant holding them when there is one
+func (s *oauthScan) addScopes(scopes []oauthScope, value, source string) []oauthScope {
+next:
+	for _, name := range splitScopes(value) {
+		for _, scope := range scopes {
+			if scope.Name == name {
+				continue next
+			}
+		}
+		scope := oauthScope{Name: name, Source: source}
+		if constant, ok := s.scopeConstants[name]; ok {
+			scope.Source = constant
+		}
+		scopes = append(scopes, scope)
+	}
+	return scopes
+}
+
+// appendDistinct appends a value not already in values
+func appendDistinct(values []string, value string) []string {
+	for _, v := range values {
+		if v == value {
+			return values
+		}
+	}
+	return append(values, value)
+}
+
+// applyOAuth adds a security definition per OAuth2 flow, named oauth2 when there is only one.
+// Flows missing a URL swagger requires are reported and skipped.
+func applyOAuth(spec *swagger.Swagger) {
+	if len(openIDIssuers) > 0 {
+		spec.AddExtension("x-openid-issuers", openIDIssuers)
+	}
+
+	var flows []oauthFlow
+	seen := map[string]int{}
+	for _, f := range oauthFlows {
+		if f.Flow == "accessCode" && f.AuthorizationURL == "" {
+			// a token endpoint exchanging codes, described by the AppAuth flow when there is one
+			if !hasAuthorizationCodeFlow() {
+				addDiagnostic("%s exchanges authorization codes but no authorization URL was found", f.Source)
+			}
+			continue
+		}
+		key := f.Flow + " " + f.AuthorizationURL + " " + f.TokenURL
+		if i, ok := seen[key]; ok {
+			// the same flow found twice, e.g. the same token endpoint called with and without a client id
+			for _, id := range f.ClientIDs {
+				flows[i].ClientIDs = appendDistinct(flows[i].ClientIDs, id)
+			}
+			flows[i].Scopes = append(flows[i].Scopes, f.Scopes...)
+			continue
+		}
+		seen[key] = len(flows)
+		flows = append(flows, f)
+	}
+
+	for i, f := range flows {
+		var scheme *swagger.SecurityScheme
+		switch f.Flow {
+		case "accessCode":
+			scheme = swagger.OAuth2AccessToken(f.AuthorizationURL, f.TokenURL)
+		case "password":
+			scheme = swagger.OAuth2Password(f.TokenURL)
+		case "application":
+			scheme = swagger.OAuth2Application(f.TokenURL)
+		}
+		scheme.Description = "Found in " + f.Source
+		for _, s := range f.Scopes {
+			if _, ok := scheme.Scopes[s.Name]; !ok {
+				scheme.AddScope(s.Name, "From "+s.Source)
+			}
+		}
+		if len(f.ClientIDs) > 0 {
+			scheme.AddExtension("x-client-ids", f.ClientIDs)
+		}
+		name := "oauth2"
+		if len(flows) > 1 {
+			name = fmt.Sprintf("oauth2_%s", f.Flow)
+			if _, taken := spec.SecurityDefinitions[name]; taken {
+				name = fmt.Sprintf("oauth2_%s_%d", f.Flow, i+1)
+			}
+		}
+		if spec.SecurityDefinitions == nil {
+			spec.SecurityDefinitions = swagger.SecurityDefinitions{}
+		}
+		spec.SecurityDefinitions[name] = scheme
+	}
+}
+
+// hasAuthorizationCodeFlow reports whether an authorization code flow with its authorization URL was found
+func hasAuthorizationCodeFlow() bool {
+	for _, f := range oauthFlows {
+		if f.Flow == "accessCode" && f.AuthorizationURL != "" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,81 @@
+package parser
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestOAuth(t *testing.T) {
+	isolateGlobals(t)
+	diagnostics = nil
+	classToFilePath["Lcom/example/di/ApiConfig;"] = "testdata/ApiConfig.smali"
+	classToFilePath["Lcom/example/di/NetworkModule;"] = "testdata/NetworkModule.smali"
+	classToFilePath["Lcom/example/auth/AuthConfig;"] = "testdata/AuthConfig.smali"
+	if err := ScanBaseURLs([]string{"testdata/NetworkModule.smali"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := ScanOAuth([]string{
+		"testdata/AuthApi.smali", "testdata/AuthConfig.smali",
+		"testdata/TokenRepository.smali", "testdata/LoginActivity.smali",
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	spec, err := GenerateSwaggerSpec(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	code, ok := spec.SecurityDefinitions["oauth2_accessCode"]
+	if !ok || code.Type != "oauth2" || code.Flow != "accessCode" ||
+		code.AuthorizationURL != "https://login.example.com/oauth2/authorize" ||
+		code.TokenURL != "https://login.example.com/oauth2/token" {
+		t.Errorf("Expected the authorization code flow of the AppAuth configuration, got %+v", code)
+	}
+	if ids, _ := code.Extensions["x-client-ids"].([]string); len(ids) != 1 || ids[0] != "android-app" {
+		t.Errorf("Expected the client id of the AppAuth request, got %v", code.Extensions["x-client-ids"])
+	}
+
+	password, ok := spec.SecurityDefinitions["oauth2_password"]
+	if !ok || password.Flow != "password" || password.TokenURL != "https://auth.example.com/oauth/token" {
+		t.Errorf("Expected a password flow on the token endpoint of AuthApi, got %+v", password)
+	}
+	if len(spec.SecurityDefinitions) != 2 {
+		t.Errorf("Expected the refresh_token grant not to add a flow, got %v", spec.SecurityDefinitions)
+	}
+
+	for flow, want := range map[string][]string{
+		"oauth2_accessCode": {"openid", "profile"},
+		"oauth2_password":   {"profile", "offline_access"},
+	} {
+		scopes := spec.SecurityDefinitions[flow].Scopes
+		if len(scopes) != len(want) {
+			t.Errorf("Expected %s to request %v, got %v", flow, want, scopes)
+		}
+		for _, scope := range want {
+			if _, ok := scopes[scope]; !ok {
+				t.Errorf("Expected %s to request %q, got %v", flow, scope, scopes)
+			}
+		}
+	}
+	if d := password.Scopes["profile"]; d != "From AuthConfig.SCOPE_PROFILE" {
+		t.Errorf("Expected the scope to name its constant, got %q", d)
+	}
+	if d := password.Scopes["offline_access"]; d != "From call to AuthApi.token in TokenRepository.login" {
+		t.Errorf("Expected the scope to name the call passing it, got %q", d)
+	}
+
+	// AuthConfig.SCOPE_DEVICES isn't requested by either flow, CLIENT_ID and SCOPED_STORAGE_DIR aren't scope constants
+	found := false
+	for _, d := range Diagnostics() {
+		if strings.Contains(d, "AuthConfig.SCOPE_DEVICES") {
+			found = true
+		}
+		if strings.Contains(d, "CLIENT_ID") || strings.Contains(d, "SCOPED_STORAGE_DIR") {
+			t.Errorf("Expected only scope constants to be taken for scopes, got %q", d)
+		}
+	}
+	if !found {
+		t.Errorf("Expected the unused scope constant to be reported, got %v", Diagnostics())
+	}
+}
@@ -0,0 +1,165 @@
+package parser
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// --------------------------------------------------------------------------
+// ProGuard / R8 mapping.txt, to name definitions, properties and operations
+// after the original classes and members of a minified build
+// --------------------------------------------------------------------------
+
+// Regex for a class line, e.g. `com.example.models.Device -> a.b.c:`
+var proguardClassPattern = regexp.MustCompile(`^(\S+) -> (\S+):$`)
+
+// Regex for a member line, e.g. `    java.lang.String name -> a` or
+// `    1:4:retrofit2.Call getDevice(java.lang.String):12:15 -> b`
+var proguardMemberPattern = regexp.MustCompile(
+	`^\s+(?:\d+:\d+:)?(\S+) ([^\s(]+)(?:\(([^)]*)\))?(?::\d+(?::\d+)?)? -> (\S+)$`)
+
+// proguardClass holds the original names of an obfuscated class and its members
+type proguardClass struct {
+	Original string            // binary name, e.g. com/example/models/Device
+	Fields   map[string]string // obfuscated => original
+	Methods  map[string]string // obfuscated name and smali params, e.g. "a(Ljava/lang/String;)" => original
+}
+
+// Obfuscated binary class name => original names, nil without a mapping file
+var proguardClasses map[string]*proguardClass
+
+// LoadProguardMapping reads the mapping.txt of a minified build
+func LoadProguardMapping(filePath string) error {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	type method struct {
+		class                  *proguardClass
+		obfuscated, name, args string
+	}
+	classes := map[string]*proguardClass{}
+	obfuscatedNames := map[string]string{} // original => obfuscated binary name, to rebuild method params
+	var methods []method
+	var current *proguardClass
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 1024*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(strings.TrimSpace(line), "#") || strings.TrimSpace(line) == "" {
+			continue
+		}
+		if m := proguardClassPattern.FindStringSubmatch(line); m != nil {
+			original, obfuscated := binaryClassName(m[1]), binaryClassName(m[2])
+			current = &proguardClass{Original: original, Fields: map[string]string{}, Methods: map[string]string{}}
+			classes[obfuscated] = current
+			obfuscatedNames[original] = obfuscated
+			continue
+		}
+		m := proguardMemberPattern.FindStringSubmatch(line)
+		if m == nil || current == nil {
+			log.Printf("Skipping mapping line %q", line)
+			continue
+		}
+		name, obfuscated := m[2], m[4]
+		if strings.Contains(name, ".") {
+			// a method of another class inlined here
+			continue
+		}
+		if !strings.Contains(line, "(") {
+			current.Fields[obfuscated] = name
+			continue
+		}
+		methods = append(methods, method{class: current, obfuscated: obfuscated, name: name, args: m[3]})
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("reading %s: %w", filePath, err)
+	}
+
+	// method params are written with original class names, smali has the obfuscated ones
+	for _, m := range methods {
+		var params strings.Builder
+		if m.args != "" {
+			for _, arg := range strings.Split(m.args, ",") {
+				params.WriteString(javaTypeDescriptor(strings.TrimSpace(arg), obfuscatedNames))
+			}
+		}
+		key := m.obfuscated + "(" + params.String() + ")"
+		if _, ok := m.class.Methods[key]; !ok {
+			m.class.Methods[key] = m.name
+		}
+	}
+	log.Printf("Loaded mapping of %d classes from %s", len(classes), filePath)
+	proguardClasses = classes
+	return nil
+}
+
+// javaTypeDescriptor turns a java type of the mapping file into a smali descriptor,
+// e.g. com.example.Device[] => [La/b;
+func javaTypeDescriptor(javaType string, obfuscatedNames map[string]string) string {
+	dims := ""
+	for strings.HasSuffix(javaType, "[]") {
+		dims += "["
+		javaType = strings.TrimSuffix(javaType, "[]")
+	}
+	switch javaType {
+	case "void":
+		return dims + "V"
+	case "boolean":
+		return dims + "Z"
+	case "byte":
+		return dims + "B"
+	case "char":
+		return dims + "C"
+	case "short":
+		return dims + "S"
+	case "int":
+		return dims + "I"
+	case "long":
+		return dims + "J"
+	case "float":
+		return dims + "F"
+	case "double":
+		return dims + "D"
+	}
+	name := binaryClassName(javaType)
+	if obfuscated, ok := obfuscatedNames[name]; ok {
+		name = obfuscated
+	}
+	return dims + "L" + name + ";"
+}
+
+// originalClassName returns the original descriptor of an obfuscated class descriptor, e.g. La/b; => Lcom/example/Device;
+func originalClassName(sig string) string {
+	if c, ok := proguardClasses[binaryClassName(sig)]; ok {
+		return "L" + c.Original + ";"
+	}
+	return sig
+}
+
+// originalFieldName returns the original name of a field of an obfuscated class
+func originalFieldName(classSig, field string) (string, bool) {
+	c, ok := proguardClasses[binaryClassName(classSig)]
+	if !ok {
+		return "", false
+	}
+	name, ok := c.Fields[field]
+	return name, ok && name != field
+}
+
+// originalMethodName returns the original name of a method of an obfuscated class, paramsSig being its smali params
+func originalMethodName(classSig, method, paramsSig string) (string, bool) {
+	c, ok := proguardClasses[binaryClassName(classSig)]
+	if !ok {
+		return "", false
+	}
+	name, ok := c.Methods[method+"("+paramsSig+")"]
+	return name, ok && name != method
+}
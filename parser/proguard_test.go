@@ -0,0 +1,90 @@
+package parser
+
+import (
+	"testing"
+)
+
+const obfuscatedApiSmali = `.class public interface abstract La/b;
+.super Ljava/lang/Object;
+
+# virtual methods
+.method public abstract a(Ljava/lang/String;)Lretrofit2/Call;
+    .param p1    # Ljava/lang/String;
+        .annotation runtime Lretrofit2/http/Path;
+            value = "id"
+        .end annotation
+    .end param
+    .annotation system Ldalvik/annotation/Signature;
+        value = {
+            "(",
+            "Ljava/lang/String;",
+            ")",
+            "Lretrofit2/Call<",
+            "La/a;",
+            ">;"
+        }
+    .end annotation
+
+    .annotation runtime Lretrofit2/http/GET;
+        value = "accounts/{id}"
+    .end annotation
+.end method
+
+.method public abstract a(La/a;)Lretrofit2/Call;
+    .param p1    # La/a;
+        .annotation runtime Lretrofit2/http/Body;
+        .end annotation
+    .end param
+    .annotation runtime Lretrofit2/http/POST;
+        value = "accounts"
+    .end annotation
+.end method
+`
+
+func TestProguardMapping(t *testing.T) {
+	isolateGlobals(t)
+	if err := LoadProguardMapping("testdata/mapping.txt"); err != nil {
+		t.Fatal(err)
+	}
+	classToFilePath["La/a;"] = "testdata/ObfuscatedAccount.smali"
+
+	apis, err := ExtractAPIEndpoints(obfuscatedApiSmali)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(apis) != 2 || apis[0].MethodName != "getAccount" || apis[1].MethodName != "saveAccount" {
+		t.Fatalf("Expected overloads to be told apart by their params, got %+v", apis)
+	}
+	spec, err := GenerateSwaggerSpec(apis)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	get := spec.Paths.Paths["/accounts/{id}"].Get
+	if get.Extensions["x-obfuscated-name"] != "a" {
+		t.Errorf("Expected the obfuscated method name, got %v", get.Extensions)
+	}
+	if resp := get.Responses.StatusCodeResponses[200]; resp.Schema.Ref.String() != "#/definitions/Account" {
+		t.Errorf("Expected an Account response, got %+v", resp.Schema)
+	}
+	def, ok := spec.Definitions["Account"]
+	if !ok {
+		t.Fatalf("Expected an Account definition, got %v", spec.Definitions)
+	}
+	if def.Extensions["x-obfuscated-name"] != "a.a" {
+		t.Errorf("Expected the obfuscated class name, got %v", def.Extensions)
+	}
+	email, ok := def.Properties["email"]
+	if !ok || email.Extensions["x-obfuscated-name"] != "a" {
+		t.Errorf("Expected email named after the original field, got %v", def.Properties)
+	}
+	if _, ok := def.Properties["loginCount"]; !ok {
+		t.Errorf("Expected loginCount, got %v", def.Properties)
+	}
+	// swagger ignores the siblings of a $ref, the extensions go on an allOf wrapping it
+	plan := def.Properties["plan"]
+	if plan.Ref.String() != "" || len(plan.AllOf) != 1 || plan.AllOf[0].Ref.String() != "#/definitions/Plan" ||
+		plan.Extensions["x-obfuscated-name"] != "c" {
+		t.Errorf("Expected plan to wrap its Plan reference with its extensions, got %+v", plan)
+	}
+}
@@ -0,0 +1,215 @@
+package parser
+
+import (
+	"encoding/xml"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// --------------------------------------------------------------------------
+// Android string resources of an apktool output, for URLs read with getString(R.string.x)
+// --------------------------------------------------------------------------
+
+// Regexes for the int a resource id flows through, e.g. `const v1, 0x7f120034`
+// or `sget v1, Lcom/example/R$string;->api_base_url:I`
+var (
+	constIntPattern = regexp.MustCompile(`^const(?:/4|/16|/high16)?\s+([vp]\d+),\s+(-?(?:0x[0-9a-fA-F]+|\d+))$`)
+	sgetIntPattern  = regexp.MustCompile(`^sget\s+([vp]\d+),\s+(L[^;]+;)->([^:]+):I$`)
+)
+
+// resourceString is the value of a string resource in one resource directory
+type resourceString struct {
+	Config string // resource directory, e.g. "values" or "values-de"
+	Value  string
+}
+
+// String resources by name, the default res/values first
+var stringResources = map[string][]resourceString{}
+
+// Names of string resources by id, from res/values/public.xml
+var stringResourceIDs = map[int64]string{}
+
+// Layouts of res/values*/strings.xml and res/values/public.xml
+type resourcesXML struct {
+	Strings []struct {
+		Name  string `xml:"name,attr"`
+		Value string `xml:",chardata"`
+	} `xml:"string"`
+	Public []struct {
+		Type string `xml:"type,attr"`
+		Name string `xml:"name,attr"`
+		ID   string `xml:"id,attr"`
+	} `xml:"public"`
+}
+
+// LoadResources reads the string resources of an apktool output directory, dir being its root
+// or its smali directory. A missing res directory isn't an error, the input may be bare smali.
+func LoadResources(dir string) error {
+	stringResources = map[string][]resourceString{}
+	stringResourceIDs = map[int64]string{}
+
+	resDir := filepath.Join(dir, "res")
+	if _, err := os.Stat(resDir); err != nil {
+		resDir = filepath.Join(filepath.Dir(filepath.Clean(dir)), "res")
+		if _, err := os.Stat(resDir); err != nil {
+			log.Printf("No res directory next to %s, string resources won't be resolved", dir)
+			return nil
+		}
+	}
+
+	configs, err := filepath.Glob(filepath.Join(resDir, "values*"))
+	if err != nil {
+		return err
+	}
+	for _, configDir := range configs {
+		config := filepath.Base(configDir)
+		var res resourcesXML
+		if ok, err := readResourcesXML(filepath.Join(configDir, "strings.xml"), &res); err != nil {
+			return err
+		} else if !ok {
+			continue
+		}
+		for _, s := range res.Strings {
+			stringResources[s.Name] = append(stringResources[s.Name], resourceString{Config: config, Value: androidStringValue(s.Value)})
+		}
+	}
+	for _, values := range stringResources {
+		sort.SliceStable(values, func(i, j int) bool {
+			if values[i].Config == "values" || values[j].Config == "values" {
+				return values[i].Config == "values"
+			}
+			return values[i].Config < values[j].Config
+		})
+	}
+
+	var public resourcesXML
+	if _, err := readResourcesXML(filepath.Join(resDir, "values", "public.xml"), &public); err != nil {
+		return err
+	}
+	for _, p := range public.Public {
+		if p.Type != "string" {
+			continue
+		}
+		if id, err := strconv.ParseInt(p.ID, 0, 64); err == nil {
+			stringResourceIDs[id] = p.Name
+		}
+	}
+	log.Printf("Loaded %d string resources and %d resource ids from %s", len(stringResources), len(stringResourceIDs), resDir)
+	return nil
+}
+
+// readResourcesXML decodes a resources file, reporting false when it doesn't exist
+func readResourcesXML(filePath string, res *resourcesXML) (bool, error) {
+	data, err := os.ReadFile(filePath)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if err := xml.Unmarshal(data, res); err != nil {
+		return false, fmt.Errorf("parsing %s: %w", filePath, err)
+	}
+	return true, nil
+}
+
+// androidStringValue decodes the text of a <string>: surrounding quotes and backslash escapes
+func androidStringValue(raw string) string {
+	s := strings.TrimSpace(raw)
+	if len(s) >= 2 && strings.HasPrefix(s, `"`) && strings.HasSuffix(s, `"`) {
+		s = s[1 : len(s)-1]
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i+1 == len(s) {
+			b.WriteByte(s[i])
+			continue
+		}
+		i++
+		switch s[i] {
+		case 'n':
+			b.WriteByte('\n')
+		case 't':
+			b.WriteByte('\t')
+		default:
+			b.WriteByte(s[i]) // \' \" \@ \? \\
+		}
+	}
+	return b.String()
+}
+
+// resolveStringResourceName walks back from lines[idx] to the string resource id last put in reg
+func resolveStringResourceName(lines []string, idx int, reg string, depth int) (string, bool) {
+	if depth > maxResolveDepth {
+		return "", false
+	}
+	for i := idx - 1; i >= 0; i-- {
+		line := lines[i]
+		if m := constIntPattern.FindStringSubmatch(line); m != nil && m[1] == reg {
+			id, err := strconv.ParseInt(m[2], 0, 64)
+			if err != nil {
+				return "", false
+			}
+			return stringResourceName(id)
+		}
+		if m := sgetIntPattern.FindStringSubmatch(line); m != nil && m[1] == reg {
+			if !strings.HasSuffix(m[2], "/R$string;") {
+				return "", false
+			}
+			return m[3], true
+		}
+		if writesRegister(line, reg) {
+			return "", false
+		}
+	}
+	return "", false
+}
+
+// stringResourceName looks a resource id up in public.xml, then in the R$string classes
+func stringResourceName(id int64) (string, bool) {
+	if name, ok := stringResourceIDs[id]; ok {
+		return name, true
+	}
+	for class := range classToFilePath {
+		if !strings.HasSuffix(class, "/R$string;") {
+			continue
+		}
+		content, ok := readClassContent(class)
+		if !ok {
+			continue
+		}
+		for _, f := range parseSmaliFields(content) {
+			if v, err := strconv.ParseInt(f.Value, 0, 64); err == nil && v == id && f.TypeSig == "I" {
+				stringResourceIDs[id] = f.Name
+				return f.Name, true
+			}
+		}
+	}
+	return "", false
+}
+
+// stringResourceValue returns the default value of a string resource, with its other resource directories as variants
+func stringResourceValue(name string) (stringValue, bool) {
+	values := stringResources[name]
+	if len(values) == 0 {
+		addDiagnostic("string resource %s not found, is the input an apktool output with its res directory?", name)
+		return stringValue{}, false
+	}
+	source := func(r resourceString) string {
+		if r.Config == "values" {
+			return "R.string." + name
+		}
+		return "R.string." + name + " (" + r.Config + ")"
+	}
+	v := stringValue{Value: values[0].Value, Source: source(values[0])}
+	for _, r := range values[1:] {
+		v.Variants = append(v.Variants, stringValue{Value: r.Value, Source: source(r)})
+	}
+	return v, true
+}
@@ -0,0 +1,187 @@
+package parser
+
+import (
+	"strings"
+)
+
+// PropertyInfo is what a serialization library says about a model field
+type PropertyInfo struct {
+	Name       string   // name on the wire
+	Alternates []string // other names accepted when deserializing
+	Ignored    bool     // never serialized
+	Required   bool     // must be present
+	Optional   bool     // explicitly allowed to be absent, e.g. Jackson @JsonInclude(NON_NULL)
+}
+
+// SerializerReader reads the annotations of one serialization library
+type SerializerReader interface {
+	Name() string
+	// AnnotationPrefix is the package holding the library's annotations, used to
+	// figure out which library a class is serialized with, e.g. "Lcom/squareup/moshi/"
+	AnnotationPrefix() string
+	ReadField(class *SmaliClass, field SmaliField) PropertyInfo
+}
+
+// serializerReaders is consulted in order, the first library whose annotations show up
+// on a class wins. Gson is the fallback for classes without any annotations.
+var serializerReaders = []SerializerReader{
+	gsonReader{},
+	moshiReader{},
+	jacksonReader{},
+	kotlinxReader{},
+}
+
+// RegisterSerializerReader adds a reader for another serialization library, or replaces
+// the reader with the same name, e.g. "gson" to change the fallback for unannotated classes.
+// Added readers are consulted after the built-in ones.
+func RegisterSerializerReader(reader SerializerReader) {
+	for i, r := range serializerReaders {
+		if r.Name() == reader.Name() {
+			serializerReaders[i] = reader
+			return
+		}
+	}
+	serializerReaders = append(serializerReaders, reader)
+}
+
+// serializerFor picks the reader for the library the class actually uses
+func serializerFor(class *SmaliClass) SerializerReader {
+	// class level annotations (@JsonClass, @Serializable, ...) are the strongest hint
+	for _, r := range serializerReaders {
+		if hasAnnotationPrefix(class.Annotations, r.AnnotationPrefix()) {
+			return r
+		}
+	}
+	for _, r := range serializerReaders {
+		for _, f := range class.Fields {
+			if hasAnnotationPrefix(f.Annotations, r.AnnotationPrefix()) {
+				return r
+			}
+		}
+	}
+	return serializerReaders[0]
+}
+
+func hasAnnotationPrefix(annotations []SmaliAnnotation, prefix string) bool {
+	for _, a := range annotations {
+		if strings.HasPrefix(a.Type, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// --------------------------------------------------------------------------
+// Gson: @SerializedName(value, alternate), @Expose, transient
+// --------------------------------------------------------------------------
+
+type gsonReader struct{}
+
+func (gsonReader) Name() string { return "gson" }
+
+func (gsonReader) AnnotationPrefix() string { return "Lcom/google/gson/annotations/" }
+
+func (gsonReader) ReadField(_ *SmaliClass, f SmaliField) PropertyInfo {
+	info := PropertyInfo{Name: f.Name, Ignored: f.hasModifier("transient")}
+	if a, ok := findAnnotation(f.Annotations, "Lcom/google/gson/annotations/SerializedName;"); ok {
+		if name, ok := a.String("value"); ok && name != "" {
+			info.Name = name
+			info.Alternates = a.Strings("alternate")
+		}
+	}
+	if a, ok := findAnnotation(f.Annotations, "Lcom/google/gson/annotations/Expose;"); ok {
+		// both default to true
+		_, hasSerialize := a.Elements["serialize"]
+		_, hasDeserialize := a.Elements["deserialize"]
+		if hasSerialize && !a.Bool("serialize") && hasDeserialize && !a.Bool("deserialize") {
+			info.Ignored = true
+		}
+	}
+	return info
+}
+
+// --------------------------------------------------------------------------
+// Moshi: @Json(name, ignore), @JsonClass, transient
+// --------------------------------------------------------------------------
+
+type moshiReader struct{}
+
+func (moshiReader) Name() string { return "moshi" }
+
+func (moshiReader) AnnotationPrefix() string { return "Lcom/squareup/moshi/" }
+
+func (moshiReader) ReadField(_ *SmaliClass, f SmaliField) PropertyInfo {
+	info := PropertyInfo{Name: f.Name, Ignored: f.hasModifier("transient")}
+	if a, ok := findAnnotation(f.Annotations, "Lcom/squareup/moshi/Json;"); ok {
+		if name, ok := a.String("name"); ok && name != "" {
+			info.Name = name
+		}
+		if a.Bool("ignore") {
+			info.Ignored = true
+		}
+	}
+	return info
+}
+
+// --------------------------------------------------------------------------
+// Jackson: @JsonProperty(value, required), @JsonIgnore, @JsonIgnoreProperties, @JsonInclude
+// --------------------------------------------------------------------------
+
+type jacksonReader struct{}
+
+func (jacksonReader) Name() string { return "jackson" }
+
+func (jacksonReader) AnnotationPrefix() string { return "Lcom/fasterxml/jackson/annotation/" }
+
+func (jacksonReader) ReadField(class *SmaliClass, f SmaliField) PropertyInfo {
+	info := PropertyInfo{Name: f.Name}
+	if a, ok := findAnnotation(f.Annotations, "Lcom/fasterxml/jackson/annotation/JsonProperty;"); ok {
+		if name, ok := a.String("value"); ok && name != "" {
+			info.Name = name
+		}
+		info.Required = a.Bool("required")
+	}
+	if a, ok := findAnnotation(f.Annotations, "Lcom/fasterxml/jackson/annotation/JsonIgnore;"); ok {
+		// @JsonIgnore(false) switches an inherited ignore off
+		_, hasValue := a.Elements["value"]
+		info.Ignored = !hasValue || a.Bool("value")
+	}
+	if a, ok := findAnnotation(class.Annotations, "Lcom/fasterxml/jackson/annotation/JsonIgnoreProperties;"); ok {
+		for _, ignored := range a.Strings("value") {
+			if ignored == info.Name {
+				info.Ignored = true
+			}
+		}
+	}
+	// @JsonInclude(NON_NULL, NON_EMPTY, ...) on the field or the class means the property may be left out
+	for _, annotations := range [][]SmaliAnnotation{f.Annotations, class.Annotations} {
+		if a, ok := findAnnotation(annotations, "Lcom/fasterxml/jackson/annotation/JsonInclude;"); ok {
+			if strings.Contains(a.Elements["value"], "->NON_") {
+				info.Optional = true
+			}
+		}
+	}
+	return info
+}
+
+// --------------------------------------------------------------------------
+// kotlinx.serialization: @Serializable, @SerialName, @Transient, @Required
+// --------------------------------------------------------------------------
+
+type kotlinxReader struct{}
+
+func (kotlinxReader) Name() string { return "kotlinx.serialization" }
+
+func (kotlinxReader) AnnotationPrefix() string { return "Lkotlinx/serialization/" }
+
+func (kotlinxReader) ReadField(_ *SmaliClass, f SmaliField) PropertyInfo {
+	info := PropertyInfo{Name: f.Name}
+	if a, ok := findAnnotation(f.Annotations, "Lkotlinx/serialization/SerialName;"); ok {
+		if name, ok := a.String("value"); ok && name != "" {
+			info.Name = name
+		}
+	}
+	_, info.Ignored = findAnnotation(f.Annotations, "Lkotlinx/serialization/Transient;")
+	_, info.Required = findAnnotation(f.Annotations, "Lkotlinx/serialization/Required;")
+	return info
+}
@@ -0,0 +1,186 @@
+package parser
+
+import (
+	"strings"
+	"testing"
+)
+
+const moshiModelSmali = `.class public final Lcom/example/MoshiModel;
+.super Ljava/lang/Object;
+
+# annotations
+.annotation runtime Lcom/squareup/moshi/JsonClass;
+    generateAdapter = true
+.end annotation
+
+
+# instance fields
+.field private final userId:Ljava/lang/String;
+    .annotation runtime Lcom/squareup/moshi/Json;
+        name = "user_id"
+    .end annotation
+.end field
+
+.field private final transient cache:Ljava/lang/String;
+`
+
+const jacksonModelSmali = `.class public Lcom/example/JacksonModel;
+.super Ljava/lang/Object;
+
+# annotations
+.annotation runtime Lcom/fasterxml/jackson/annotation/JsonIgnoreProperties;
+    value = {
+        "internal"
+    }
+.end annotation
+
+
+# instance fields
+.field private id:J
+    .annotation runtime Lcom/fasterxml/jackson/annotation/JsonProperty;
+        required = true
+        value = "ID"
+    .end annotation
+.end field
+
+.field private secret:Ljava/lang/String;
+    .annotation runtime Lcom/fasterxml/jackson/annotation/JsonIgnore;
+    .end annotation
+.end field
+
+.field private internal:Ljava/lang/String;
+`
+
+const kotlinxModelSmali = `.class public final Lcom/example/KotlinxModel;
+.super Ljava/lang/Object;
+
+# annotations
+.annotation runtime Lkotlinx/serialization/Serializable;
+.end annotation
+
+
+# instance fields
+.field private final userName:Ljava/lang/String;
+
+.field private final isAdmin:Z
+
+.field private final session:Ljava/lang/String;
+
+
+# direct methods
+.method public static synthetic getUserName$annotations()V
+    .annotation runtime Lkotlinx/serialization/SerialName;
+        value = "user_name"
+    .end annotation
+
+    .annotation runtime Lkotlinx/serialization/Required;
+    .end annotation
+
+    return-void
+.end method
+
+.method public static synthetic isAdmin$annotations()V
+    .annotation runtime Lkotlinx/serialization/SerialName;
+        value = "admin"
+    .end annotation
+
+    return-void
+.end method
+
+.method public static synthetic getSession$annotations()V
+    .annotation runtime Lkotlinx/serialization/Transient;
+    .end annotation
+
+    return-void
+.end method
+`
+
+// readProperties returns the properties the class' serializer keeps, by field name
+func readProperties(t *testing.T, content, library string) map[string]PropertyInfo {
+	class := parseSmaliClass(content)
+	reader := serializerFor(class)
+	if reader.Name() != library {
+		t.Fatalf("Expected %s to be serialized by %s, got %s", class.Name, library, reader.Name())
+	}
+	props := map[string]PropertyInfo{}
+	for _, f := range class.instanceFields() {
+		info := reader.ReadField(class, f)
+		if !info.Ignored {
+			props[f.Name] = info
+		}
+	}
+	return props
+}
+
+func TestMoshiReader(t *testing.T) {
+	props := readProperties(t, moshiModelSmali, "moshi")
+	if len(props) != 1 || props["userId"].Name != "user_id" {
+		t.Errorf("Unexpected properties %+v", props)
+	}
+}
+
+func TestJacksonReader(t *testing.T) {
+	props := readProperties(t, jacksonModelSmali, "jackson")
+	if len(props) != 1 || props["id"].Name != "ID" || !props["id"].Required {
+		t.Errorf("Unexpected properties %+v", props)
+	}
+}
+
+func TestKotlinxReader(t *testing.T) {
+	props := readProperties(t, kotlinxModelSmali, "kotlinx.serialization")
+	if len(props) != 2 {
+		t.Fatalf("Expected 2 properties, got %+v", props)
+	}
+	if props["userName"].Name != "user_name" || !props["userName"].Required {
+		t.Errorf("Unexpected userName %+v", props["userName"])
+	}
+	if props["isAdmin"].Name != "admin" {
+		t.Errorf("Unexpected isAdmin %+v", props["isAdmin"])
+	}
+}
+
+const wireModelSmali = `.class public final Lcom/example/WireModel;
+.super Ljava/lang/Object;
+
+# instance fields
+.field public final displayName:Ljava/lang/String;
+    .annotation runtime Lcom/squareup/wire/WireField;
+        jsonName = "display_name"
+        label = .enum Lcom/squareup/wire/WireField$Label;->REQUIRED:Lcom/squareup/wire/WireField$Label;
+    .end annotation
+.end field
+`
+
+// wireReader reads the generated fields of Wire protobuf messages
+type wireReader struct{}
+
+func (wireReader) Name() string { return "wire" }
+
+func (wireReader) AnnotationPrefix() string { return "Lcom/squareup/wire/" }
+
+func (wireReader) ReadField(_ *SmaliClass, f SmaliField) PropertyInfo {
+	info := PropertyInfo{Name: f.Name}
+	if a, ok := findAnnotation(f.Annotations, "Lcom/squareup/wire/WireField;"); ok {
+		if name, ok := a.String("jsonName"); ok && name != "" {
+			info.Name = name
+		}
+		info.Required = strings.HasSuffix(a.Elements["label"], "->REQUIRED:Lcom/squareup/wire/WireField$Label;")
+	}
+	return info
+}
+
+func TestRegisterSerializerReader(t *testing.T) {
+	isolateGlobals(t)
+	readers := len(serializerReaders)
+	RegisterSerializerReader(wireReader{})
+	props := readProperties(t, wireModelSmali, "wire")
+	if len(props) != 1 || props["displayName"].Name != "display_name" || !props["displayName"].Required {
+		t.Errorf("Unexpected properties %+v", props)
+	}
+
+	// replacing by name keeps a single reader
+	RegisterSerializerReader(wireReader{})
+	if len(serializerReaders) != readers+1 {
+		t.Errorf("Expected the second registration to replace the first, got %d readers", len(serializerReaders))
+	}
+}
@@ -0,0 +1,381 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+)
+
+// --------------------------------------------------------------------------
+// JVM generic signature grammar (JVMS 4.7.9.1)
+//
+// Signatures from `Ldalvik/annotation/Signature;` (and plain descriptors, which
+// are a subset) are parsed into a jvmType tree the schema builder walks.
+// --------------------------------------------------------------------------
+
+type jvmTypeKind int
+
+const (
+	jvmPrimitive jvmTypeKind = iota // Name is the descriptor char, e.g. "I" or "V"
+	jvmClass                        // Name is the binary class name, e.g. "java/util/List"
+	jvmTypeVar                      // Name is the type variable, e.g. "T"
+	jvmArray                        // Elem is the component type
+	jvmWildcard                     // Wildcard is '+', '-' or '*', Elem the bound
+)
+
+// jvmType is a node of a parsed type signature
+type jvmType struct {
+	Kind     jvmTypeKind
+	Name     string
+	Args     []*jvmType // type arguments of a class
+	Elem     *jvmType   // component of an array, bound of a wildcard
+	Wildcard byte
+}
+
+// jvmTypeParam is a declared type parameter, e.g. `T:Ljava/lang/Object;`
+type jvmTypeParam struct {
+	Name   string
+	Bounds []*jvmType
+}
+
+type jvmMethodSignature struct {
+	TypeParams []jvmTypeParam
+	Params     []*jvmType
+	Return     *jvmType
+}
+
+type jvmClassSignature struct {
+	TypeParams []jvmTypeParam
+	Super      *jvmType
+	Interfaces []*jvmType
+}
+
+// descriptor returns the erased type descriptor of a class or array, e.g. "Ljava/util/List;"
+func (t *jvmType) descriptor() string {
+	switch t.Kind {
+	case jvmClass:
+		return "L" + t.Name + ";"
+	case jvmArray:
+		return "[" + t.Elem.descriptor()
+	case jvmTypeVar:
+		return "Ljava/lang/Object;"
+	case jvmWildcard:
+		if t.Elem == nil {
+			return "Ljava/lang/Object;"
+		}
+		return t.Elem.descriptor()
+	default:
+		return t.Name
+	}
+}
+
+// String renders the type back to its signature form
+func (t *jvmType) String() string {
+	switch t.Kind {
+	case jvmClass:
+		if len(t.Args) == 0 {
+			return "L" + t.Name + ";"
+		}
+		var args []string
+		for _, a := range t.Args {
+			args = append(args, a.String())
+		}
+		return "L" + t.Name + "<" + strings.Join(args, "") + ">;"
+	case jvmTypeVar:
+		return "T" + t.Name + ";"
+	case jvmArray:
+		return "[" + t.Elem.String()
+	case jvmWildcard:
+		if t.Elem == nil {
+			return "*"
+		}
+		return string(t.Wildcard) + t.Elem.String()
+	default:
+		return t.Name
+	}
+}
+
+// isWide reports whether the type takes two registers (long and double)
+func (t *jvmType) isWide() bool {
+	return t.Kind == jvmPrimitive && (t.Name == "J" || t.Name == "D")
+}
+
+// parseTypeSignature parses a single field/type signature, e.g. "Ljava/util/List<+Lfoo/Bar;>;"
+func parseTypeSignature(sig string) (*jvmType, error) {
+	p := &signatureParser{s: strings.TrimSpace(sig)}
+	t, err := p.javaType()
+	if err != nil {
+		return nil, err
+	}
+	if !p.eof() {
+		return nil, p.errorf("unexpected trailing input")
+	}
+	return t, nil
+}
+
+// parseMethodSignature parses a method signature, e.g. "<T:Ljava/lang/Object;>(TT;I)Ljava/util/List<TT;>;"
+func parseMethodSignature(sig string) (*jvmMethodSignature, error) {
+	p := &signatureParser{s: strings.TrimSpace(sig)}
+	m := &jvmMethodSignature{}
+	var err error
+	if p.peek() == '<' {
+		if m.TypeParams, err = p.typeParameters(); err != nil {
+			return nil, err
+		}
+	}
+	if err := p.expect('('); err != nil {
+		return nil, err
+	}
+	for p.peek() != ')' {
+		if p.eof() {
+			return nil, p.errorf("unterminated parameter list")
+		}
+		t, err := p.javaType()
+		if err != nil {
+			return nil, err
+		}
+		m.Params = append(m.Params, t)
+	}
+	p.pos++ // ')'
+	if m.Return, err = p.javaType(); err != nil {
+		return nil, err
+	}
+	// throws signatures (^Lfoo;) don't matter to us
+	return m, nil
+}
+
+// parseClassSignature parses the signature of a generic class, e.g. "<T:Ljava/lang/Object;>Ljava/lang/Object;"
+func parseClassSignature(sig string) (*jvmClassSignature, error) {
+	p := &signatureParser{s: strings.TrimSpace(sig)}
+	c := &jvmClassSignature{}
+	var err error
+	if p.peek() == '<' {
+		if c.TypeParams, err = p.typeParameters(); err != nil {
+			return nil, err
+		}
+	}
+	if c.Super, err = p.classType(); err != nil {
+		return nil, err
+	}
+	for !p.eof() {
+		i, err := p.classType()
+		if err != nil {
+			return nil, err
+		}
+		c.Interfaces = append(c.Interfaces, i)
+	}
+	return c, nil
+}
+
+// parseDescriptorParams splits a raw parameter descriptor, e.g. "JLjava/lang/String;[I"
+func parseDescriptorParams(paramsSig string) ([]*jvmType, error) {
+	m, err := parseMethodSignature("(" + paramsSig + ")V")
+	if err != nil {
+		return nil, err
+	}
+	return m.Params, nil
+}
+
+type signatureParser struct {
+	s   string
+	pos int
+}
+
+func (p *signatureParser) eof() bool {
+	return p.pos >= len(p.s)
+}
+
+func (p *signatureParser) peek() byte {
+	if p.eof() {
+		return 0
+	}
+	return p.s[p.pos]
+}
+
+func (p *signatureParser) expect(c byte) error {
+	if p.peek() != c {
+		return p.errorf("expected %q", c)
+	}
+	p.pos++
+	return nil
+}
+
+func (p *signatureParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("signature %q at %d: %s", p.s, p.pos, fmt.Sprintf(format, args...))
+}
+
+// identifier reads up to the next char in stop
+func (p *signatureParser) identifier(stop string) (string, error) {
+	start := p.pos
+	for !p.eof() && !strings.ContainsRune(stop, rune(p.s[p.pos])) {
+		p.pos++
+	}
+	if p.pos == start {
+		return "", p.errorf("expected identifier")
+	}
+	return p.s[start:p.pos], nil
+}
+
+// javaType reads a JavaTypeSignature, or V for return types
+func (p *signatureParser) javaType() (*jvmType, error) {
+	switch c := p.peek(); c {
+	case 'B', 'C', 'D', 'F', 'I', 'J', 'S', 'Z', 'V':
+		p.pos++
+		return &jvmType{Kind: jvmPrimitive, Name: string(c)}, nil
+	default:
+		return p.referenceType()
+	}
+}
+
+func (p *signatureParser) referenceType() (*jvmType, error) {
+	switch p.peek() {
+	case 'L':
+		return p.classType()
+	case 'T':
+		p.pos++
+		name, err := p.identifier(";")
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(';'); err != nil {
+			return nil, err
+		}
+		return &jvmType{Kind: jvmTypeVar, Name: name}, nil
+	case '[':
+		p.pos++
+		elem, err := p.javaType()
+		if err != nil {
+			return nil, err
+		}
+		return &jvmType{Kind: jvmArray, Elem: elem}, nil
+	default:
+		return nil, p.errorf("unexpected %q", p.peek())
+	}
+}
+
+// classType reads `Lpkg/Outer<args>.Inner<args>;`, inner classes are named Outer$Inner
+func (p *signatureParser) classType() (*jvmType, error) {
+	if err := p.expect('L'); err != nil {
+		return nil, err
+	}
+	name, err := p.identifier("<.;")
+	if err != nil {
+		return nil, err
+	}
+	t := &jvmType{Kind: jvmClass, Name: name}
+	if p.peek() == '<' {
+		if t.Args, err = p.typeArguments(); err != nil {
+			return nil, err
+		}
+	}
+	for p.peek() == '.' {
+		p.pos++
+		inner, err := p.identifier("<.;")
+		if err != nil {
+			return nil, err
+		}
+		t.Name += "$" + inner
+		t.Args = nil
+		if p.peek() == '<' {
+			if t.Args, err = p.typeArguments(); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err := p.expect(';'); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+func (p *signatureParser) typeArguments() ([]*jvmType, error) {
+	p.pos++ // '<'
+	var args []*jvmType
+	for p.peek() != '>' {
+		if p.eof() {
+			return nil, p.errorf("unterminated type arguments")
+		}
+		switch c := p.peek(); c {
+		case '*':
+			p.pos++
+			args = append(args, &jvmType{Kind: jvmWildcard, Wildcard: '*'})
+		case '+', '-':
+			p.pos++
+			bound, err := p.referenceType()
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, &jvmType{Kind: jvmWildcard, Wildcard: c, Elem: bound})
+		default:
+			arg, err := p.referenceType()
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, arg)
+		}
+	}
+	p.pos++ // '>'
+	return args, nil
+}
+
+func (p *signatureParser) typeParameters() ([]jvmTypeParam, error) {
+	p.pos++ // '<'
+	var params []jvmTypeParam
+	for p.peek() != '>' {
+		if p.eof() {
+			return nil, p.errorf("unterminated type parameters")
+		}
+		name, err := p.identifier(":")
+		if err != nil {
+			return nil, err
+		}
+		param := jvmTypeParam{Name: name}
+		// class bound (may be empty) then interface bounds, each introduced by ':'
+		for p.peek() == ':' {
+			p.pos++
+			if p.peek() == ':' || p.peek() == '>' {
+				continue
+			}
+			bound, err := p.referenceType()
+			if err != nil {
+				return nil, err
+			}
+			param.Bounds = append(param.Bounds, bound)
+		}
+		params = append(params, param)
+	}
+	p.pos++ // '>'
+	return params, nil
+}
+
+// substitute replaces the type variables bound in bindings, e.g. T => FeatureStatus in `Ljava/util/List<TT;>;`
+func (t *jvmType) substitute(bindings map[string]*jvmType) *jvmType {
+	if len(bindings) == 0 {
+		return t
+	}
+	switch t.Kind {
+	case jvmTypeVar:
+		if b, ok := bindings[t.Name]; ok {
+			return b
+		}
+		return t
+	case jvmClass:
+		if len(t.Args) == 0 {
+			return t
+		}
+		c := *t
+		c.Args = make([]*jvmType, len(t.Args))
+		for i, a := range t.Args {
+			c.Args[i] = a.substitute(bindings)
+		}
+		return &c
+	case jvmArray, jvmWildcard:
+		if t.Elem == nil {
+			return t
+		}
+		c := *t
+		c.Elem = t.Elem.substitute(bindings)
+		return &c
+	default:
+		return t
+	}
+}
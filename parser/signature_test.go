@@ -0,0 +1,94 @@
+package parser
+
+import (
+	"testing"
+
+	swagger "github.com/go-openapi/spec"
+)
+
+func TestParseSignatures(t *testing.T) {
+	// signatures as kotlinc writes them into Ldalvik/annotation/Signature;
+	for _, sig := range []string{
+		"Ljava/util/HashMap<Ljava/lang/String;Ljava/util/List<Lcom/example/models/Device;>;>;",
+		"Ljava/util/List<+Lcom/example/models/Device;>;",
+		"Ljava/util/Map<Ljava/lang/String;-Ljava/lang/Integer;>;",
+		"Ljava/util/List<*>;",
+		"[[Ljava/lang/String;",
+		"[I",
+		"TT;",
+		"Lcom/example/Outer<TT;>.Inner<Ljava/lang/String;>;",
+	} {
+		typ, err := parseTypeSignature(sig)
+		if err != nil {
+			t.Errorf("parseTypeSignature(%s): %v", sig, err)
+			continue
+		}
+		want := sig
+		if sig == "Lcom/example/Outer<TT;>.Inner<Ljava/lang/String;>;" {
+			want = "Lcom/example/Outer$Inner<Ljava/lang/String;>;"
+		}
+		if typ.String() != want {
+			t.Errorf("Expected %s to round trip, got %s", want, typ)
+		}
+	}
+
+	typ, _ := parseTypeSignature("Ljava/util/HashMap<Ljava/lang/String;Ljava/util/List<Lcom/example/models/Device;>;>;")
+	if len(typ.Args) != 2 || typ.Args[1].Name != "java/util/List" || typ.Args[1].Args[0].Name != "com/example/models/Device" {
+		t.Errorf("Unexpected nested type tree %+v", typ)
+	}
+
+	for _, sig := range []string{"Ljava/util/List<Lfoo;", "Ljava/util/List", "Q", "Lfoo;Lbar;"} {
+		if _, err := parseTypeSignature(sig); err == nil {
+			t.Errorf("Expected %s to fail", sig)
+		}
+	}
+}
+
+func TestParseMethodSignature(t *testing.T) {
+	m, err := parseMethodSignature("<T:Ljava/lang/Object;R::Ljava/lang/Comparable<-TR;>;>(JTT;[Ljava/lang/String;D)Lio/reactivex/rxjava3/core/Single<Ljava/util/List<TR;>;>;")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m.TypeParams) != 2 || m.TypeParams[1].Name != "R" || m.TypeParams[1].Bounds[0].Name != "java/lang/Comparable" {
+		t.Errorf("Unexpected type params %+v", m.TypeParams)
+	}
+	if len(m.Params) != 4 || !m.Params[0].isWide() || m.Params[1].Kind != jvmTypeVar || m.Params[2].Kind != jvmArray {
+		t.Errorf("Unexpected params %+v", m.Params)
+	}
+	if m.Return.String() != "Lio/reactivex/rxjava3/core/Single<Ljava/util/List<TR;>;>;" {
+		t.Errorf("Unexpected return %s", m.Return)
+	}
+
+	c, err := parseClassSignature("<T:Ljava/lang/Object;>Lcom/example/Base<TT;>;Ljava/io/Serializable;")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(c.TypeParams) != 1 || c.Super.Name != "com/example/Base" || len(c.Interfaces) != 1 {
+		t.Errorf("Unexpected class signature %+v", c)
+	}
+
+	if i := paramIndex("JLjava/lang/String;[I", "p3"); i != 1 {
+		t.Errorf("Expected p3 to be the second param, got %d", i)
+	}
+}
+
+func TestNestedGenericSchema(t *testing.T) {
+	isolateGlobals(t)
+	classToFilePath["Lcom/example/models/Device;"] = "testdata/Device.smali"
+	spec := &swagger.Swagger{SwaggerProps: swagger.SwaggerProps{Definitions: map[string]swagger.Schema{}}}
+
+	schema, err := interpretTypeAndBuildDefinition(
+		"()Lio/reactivex/rxjava3/core/Single<Ljava/util/HashMap<Ljava/lang/String;Ljava/util/List<+Lcom/example/models/Device;>;>;>;", spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	values := schema.AdditionalProperties.Schema
+	if !schema.Type.Contains("object") || !values.Type.Contains("array") || values.Items.Schema.Ref.String() != "#/definitions/Device" {
+		t.Errorf("Expected a map of Device arrays, got %+v", schema)
+	}
+
+	schema, err = interpretTypeAndBuildDefinition("Lretrofit2/Call<Lretrofit2/Response<Ljava/lang/Void;>;>;", spec)
+	if err != nil || schema != nil {
+		t.Errorf("Expected no content, got %+v %v", schema, err)
+	}
+}
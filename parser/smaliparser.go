@@ -1,11 +1,14 @@
 package parser
 
 import (
+	"encoding/json"
 	"fmt"
 	"log"
 	"os"
 	"regexp"
 	"runtime"
+	"sort"
+	"strconv"
 	"strings"
 
 	swagger "github.com/go-openapi/spec"
@@ -18,44 +21,103 @@ import (
 // We'll store fully qualified class names -> absolute file paths
 var classToFilePath = make(map[string]string)
 
-// Keep track of object types we've already parsed to avoid recursion loops
-var parsedTypes = make(map[string]bool)
-
-// Regex for `.class ... Luk/co/goptions/...;`
-var classDefPattern = regexp.MustCompile(`\.class(?:\s+\w+)*\s+([\w/]+);`)
+// Problems found while extracting endpoints or generating the spec that didn't stop generation
+var diagnostics []string
 
 // Regex to capture the method definition
 var methodPattern = regexp.MustCompile(
 	`(?m)^\.method\s+(public|private|protected)(?:\s+[\w$]+)*\s+([A-Za-z0-9_$]+)\(([^)]*)\)(\S*)\s*([\s\S]*?)\.end method`)
 
-// Regex to find retrofit annotation in the body
-var retrofitHTTPAnnotation = regexp.MustCompile(
-	`(?s)\.annotation\s+runtime\s+Lretrofit2/http/([A-Z]+);\s*value\s*=\s*"([^"]+)"`)
+// Regex to match a retrofit HTTP verb annotation type, e.g. `Lretrofit2/http/GET;` or `Lretrofit2/http/HTTP;`
+var retrofitHTTPAnnotation = regexp.MustCompile(`^Lretrofit2/http/([A-Z]+);$`)
 
 // Regex to find parameter blocks
-var paramBlockPattern = regexp.MustCompile(`(?s)\.param\s+([vp0-9]+)\s+#\s+(\S+)\s*(.*?)\.end param`)
+// A `.param` line, optionally named from debug info, e.g. `.param p1, "systemId"    # Ljava/lang/String;`.
+// It only opens a block closed by `.end param` when annotations follow.
+var paramBlockPattern = regexp.MustCompile(
+	`(?m)^[ \t]*\.param[ \t]+([vp]\d+)(?:[ \t]*,[ \t]*"((?:[^"\\]|\\.)*)")?[ \t]*(?:#[ \t]*(\S+))?[ \t]*$`)
 
-var paramPathAnnotation = regexp.MustCompile(
-	`(?s)\.annotation\s+runtime\s+Lretrofit2/http/Path;\s*value\s*=\s*"([^"]+)"`)
-var paramQueryAnnotation = regexp.MustCompile(
-	`(?s)\.annotation\s+runtime\s+Lretrofit2/http/Query;\s*value\s*=\s*"([^"]+)"`)
+// Regex to match a retrofit parameter annotation type, e.g. `Lretrofit2/http/Path;`
+var retrofitParamAnnotation = regexp.MustCompile(`^Lretrofit2/http/(\w+);$`)
 
 // Regex to find the @Signature annotation for the return type
 var signatureAnnotation = regexp.MustCompile(
 	`(?s)\.annotation\s+system\s+Ldalvik/annotation/Signature;\s*value\s*=\s*{\s*(.*?)\s*}\s*\.end annotation`)
 
+// Regex for the class header, capturing modifiers and the class type, e.g. `.class public final enum Lfoo/Bar;`
+var classHeaderPattern = regexp.MustCompile(`(?m)^\.class[ \t]+((?:[\w-]+[ \t]+)*)(L[^;\s]+;)`)
+
+// Regex for the superclass and implemented interfaces, e.g. `.super Lfoo/BaseResponse;`
+var superPattern = regexp.MustCompile(`(?m)^\.super[ \t]+(L[^;\s]+;)`)
+var implementsPattern = regexp.MustCompile(`(?m)^\.implements[ \t]+(L[^;\s]+;)`)
+
+// Packages of platform and library classes, inheritance stops there
+var frameworkPackages = []string{
+	"Ljava/", "Ljavax/", "Landroid/", "Landroidx/", "Ldalvik/", "Lkotlin/", "Lkotlinx/",
+	"Lcom/google/gson/", "Lcom/squareup/moshi/", "Lcom/fasterxml/jackson/",
+}
+
+// Regex for the first field or method of a class, class annotations are declared before it
+var memberStartPattern = regexp.MustCompile(`(?m)^\.(?:field|method)\s`)
+
 // Regex to find fields in a smali class, e.g. `.field private final name:Ljava/lang/String;`
-var fieldPattern = regexp.MustCompile(`(?m)^\.field\s+(?:public|private|protected)?(?:\s+final)?\s+(\w+):([^;]+;)`)
+// or `.field public static final URL:Ljava/lang/String; = "https://..."`
+var fieldPattern = regexp.MustCompile(`(?m)^\.field[ \t]+((?:[\w-]+[ \t]+)*?)([^\s:]+):(\S+)(?:[ \t]*=[ \t]*(.*))?$`)
 
 // --------------------------------------------------------------------------
 // 2) DATA STRUCTS
 // --------------------------------------------------------------------------
 
 type SmaliParam struct {
-	Register string // e.g. "p1"
-	TypeSig  string // e.g. "Ljava/lang/String;"
-	PathVar  string // e.g. "systemId"
-	QueryVar string // e.g. "featureName"
+	Register   string // e.g. "p1"
+	TypeSig    string // e.g. "Ljava/lang/String;"
+	Signature  string // generic type from the method @Signature, e.g. "Ljava/util/List<Ljava/lang/Long;>;"
+	Name       string // parameter name from debug info or kotlin metadata, e.g. "systemId"
+	Annotation string // retrofit annotation, e.g. "Path", "HeaderMap"
+	PathVar    string // e.g. "systemId"
+	QueryVar   string // e.g. "featureName"
+	HeaderVar  string // e.g. "X-Tenant-Id"
+	FieldVar   string // e.g. "username"
+	PartVar    string // e.g. "avatar", empty for a self describing MultipartBody.Part
+}
+
+// StaticHeader is a fixed header from a method level @Headers annotation
+type StaticHeader struct {
+	Name  string // e.g. "Accept"
+	Value string // e.g. "application/json"
+}
+
+// SmaliField is a `.field` declaration together with its annotations
+type SmaliField struct {
+	Name         string   // java field name, e.g. "name"
+	OriginalName string   // name before obfuscation, from the proguard mapping
+	TypeSig      string   // e.g. "Ljava/lang/String;"
+	Modifiers    []string // e.g. private, static, final
+	Value        string   // raw initial value of static fields, if any
+	Annotations  []SmaliAnnotation
+}
+
+// signature returns the generic signature of the field when it has one
+// (e.g. "Ljava/util/List<Lfoo/Device;>;"), its erased type otherwise
+func (f SmaliField) signature() string {
+	if a, ok := findAnnotation(f.Annotations, "Ldalvik/annotation/Signature;"); ok {
+		if sig := strings.Join(a.Strings("value"), ""); sig != "" {
+			return sig
+		}
+	}
+	return f.TypeSig
+}
+
+// SmaliClass is the part of a class file needed to build a model definition
+type SmaliClass struct {
+	Name        string            // e.g. "Lcom/example/Foo;"
+	Modifiers   []string          // e.g. public, final, enum
+	Super       string            // e.g. "Lcom/example/BaseResponse;"
+	Interfaces  []string          // from .implements
+	Annotations []SmaliAnnotation // class level annotations
+	Fields      []SmaliField
+	Kotlin      *kotlinClassMetadata // nil for java classes
+	Signature   *jvmClassSignature   // type parameters and generic supertypes, nil for non generic classes
 }
 
 type SmaliMethod struct {
@@ -66,20 +128,43 @@ type SmaliMethod struct {
 	Body            string
 	HTTPVerb        string
 	HTTPPath        string
+	HasBody         bool // POST/PUT/PATCH, or @HTTP(hasBody = true)
+	FormURLEncoded  bool // @FormUrlEncoded
+	Multipart       bool // @Multipart
+	Suspend         bool // kotlin suspend fun, the trailing Continuation param is dropped from Params
+	Headers         []StaticHeader
 	Params          []SmaliParam
 	ReturnSignature string // from @Signature annotation
 }
 
 // APIEndpoint for swagger
 type APIEndpoint struct {
+	ClassName       string // retrofit interface declaring the method, e.g. "Lcom/example/FeaturesApi;"
 	Path            string
 	Method          string
 	MethodName      string
 	ReturnType      string
+	HasBody         bool
+	FormURLEncoded  bool
+	Multipart       bool
+	DynamicURL      bool   // the full URL is passed at runtime through an @Url param
+	ObfuscatedName  string // name of the method in the smali when MethodName comes from the proguard mapping
+	Headers         []StaticHeader
 	Params          []SmaliParam
 	ReturnSignature string
 }
 
+// Diagnostics returns the problems reported so far that didn't stop spec generation
+func Diagnostics() []string {
+	return diagnostics
+}
+
+func addDiagnostic(format string, args ...interface{}) {
+	msg := fmt.Sprintf(format, args...)
+	log.Printf("WARNING: %s", msg)
+	diagnostics = append(diagnostics, msg)
+}
+
 // --------------------------------------------------------------------------
 // 3) SCANNING ALL .SMALI => BUILD classToFilePath
 // --------------------------------------------------------------------------
@@ -93,12 +178,10 @@ func ScanAllSmaliClasses(files []string) error {
 			log.Printf("Could not read %s: %v", path, err)
 			continue
 		}
-		matches := classDefPattern.FindStringSubmatch(string(content))
+		// the class header pattern keeps inner and companion classes, e.g. Lfoo/Config$Companion;
+		matches := classHeaderPattern.FindStringSubmatch(string(content))
 		if matches != nil {
-			clsName := matches[1]
-			if !strings.HasSuffix(clsName, ";") {
-				clsName += ";"
-			}
+			clsName := matches[2]
 			log.Printf("Found class: %s => file: %s", clsName, path)
 			classToFilePath[clsName] = path
 		}
@@ -128,45 +211,88 @@ func parseSmaliMethods(content string) []SmaliMethod {
 	return methods
 }
 
+// paramBlock is a `.param` declaration with the annotations up to its `.end param`
+type paramBlock struct {
+	Register   string // e.g. "p2"
+	Name       string // e.g. "systemId", from debug info
+	TypeSig    string // e.g. "J" or "Ljava/lang/String;"
+	Body       string // everything until .end param
+	start, end int    // offsets of the whole block in the method body
+}
+
+func findParamBlocks(body string) []paramBlock {
+	var blocks []paramBlock
+	for _, idx := range paramBlockPattern.FindAllStringSubmatchIndex(body, -1) {
+		b := paramBlock{Register: body[idx[2]:idx[3]], start: idx[0], end: idx[1]}
+		if idx[4] != -1 {
+			b.Name = unquoteSmali(`"` + body[idx[4]:idx[5]] + `"`)
+		}
+		if idx[6] != -1 {
+			b.TypeSig = body[idx[6]:idx[7]]
+		}
+		rest := body[idx[1]:]
+		if strings.HasPrefix(strings.TrimSpace(rest), ".annotation") {
+			if end := strings.Index(rest, ".end param"); end != -1 {
+				b.Body = rest[:end]
+				b.end = idx[1] + end + len(".end param")
+			}
+		}
+		blocks = append(blocks, b)
+	}
+	return blocks
+}
+
 func parseMethodParams(method *SmaliMethod) {
-	blocks := paramBlockPattern.FindAllStringSubmatch(method.Body, -1)
+	blocks := findParamBlocks(method.Body)
 	log.Printf("parseMethodParams: method %s has %d param blocks", method.Name, len(blocks))
 
 	var results []SmaliParam
 	for _, b := range blocks {
-		register := b[1]                   // e.g. "p2"
-		typeSig := strings.TrimSpace(b[2]) // e.g. "J" or "Ljava/lang/String;"
-		body := b[3]                       // everything until .end param
+		typeSig := strings.TrimSpace(b.TypeSig)
+		if typeSig == "" {
+			if i := paramIndex(method.ParamsSig, b.Register); i != -1 {
+				params, _ := parseDescriptorParams(method.ParamsSig)
+				typeSig = params[i].String()
+			}
+		}
+		body := b.Body
 
 		// base param
 		baseParam := SmaliParam{
-			Register: register,
+			Register: b.Register,
+			Name:     b.Name,
 			TypeSig:  typeSig,
 		}
 
-		// find all path annotations in this block
-		pathMatches := paramPathAnnotation.FindAllStringSubmatch(body, -1)
-		// find all query annotations
-		queryMatches := paramQueryAnnotation.FindAllStringSubmatch(body, -1)
-
-		if len(pathMatches) == 0 && len(queryMatches) == 0 {
-			// no path or query => keep as-is (maybe body param)
-			results = append(results, baseParam)
-			continue
-		}
-
-		// for each path annotation => add a new param
-		for _, pm := range pathMatches {
+		// for each retrofit annotation => add a new param
+		found := false
+		for _, a := range parseAnnotations(body) {
+			matches := retrofitParamAnnotation.FindStringSubmatch(a.Type)
+			if matches == nil {
+				continue
+			}
 			newParam := baseParam
-			newParam.PathVar = pm[1] // e.g. "resourceId"
+			newParam.Annotation = matches[1]
+			value, _ := a.String("value")
+			switch newParam.Annotation {
+			case "Path":
+				newParam.PathVar = value // e.g. "resourceId"
+			case "Query":
+				newParam.QueryVar = value // e.g. "startDatetime"
+			case "Header":
+				newParam.HeaderVar = value // e.g. "Authorization"
+			case "Field":
+				newParam.FieldVar = value // e.g. "password"
+			case "Part":
+				newParam.PartVar = value // e.g. "avatar"
+			}
 			results = append(results, newParam)
+			found = true
 		}
 
-		// for each query annotation => add a new param
-		for _, qm := range queryMatches {
-			newParam := baseParam
-			newParam.QueryVar = qm[1] // e.g. "startDatetime"
-			results = append(results, newParam)
+		if !found {
+			// no retrofit annotation => keep as-is (maybe body param)
+			results = append(results, baseParam)
 		}
 	}
 
@@ -178,6 +304,26 @@ func parseSignatureAnnotation(method *SmaliMethod) {
 		sig := mergeSignatureLines(sigMatches[1])
 		method.ReturnSignature = sig
 		log.Printf("Method %s has ReturnSignature = %s", method.Name, sig)
+		parseParamSignatures(method, sig)
+	}
+}
+
+// parseParamSignatures gives the params their generic type from the method signature, e.g. List<Long>
+func parseParamSignatures(method *SmaliMethod, sig string) {
+	parsed, err := parseMethodSignature(sig)
+	if err != nil {
+		return
+	}
+	descriptors, err := parseDescriptorParams(method.ParamsSig)
+	if err != nil || len(parsed.Params) != len(descriptors) {
+		// e.g. inner class constructors, whose signature leaves the outer instance out
+		return
+	}
+	for i := range method.Params {
+		p := &method.Params[i]
+		if idx := paramIndex(method.ParamsSig, p.Register); idx != -1 && len(parsed.Params[idx].Args) > 0 {
+			p.Signature = parsed.Params[idx].String()
+		}
 	}
 }
 
@@ -193,39 +339,378 @@ func mergeSignatureLines(sigBlock string) string {
 	return strings.Join(tokens, "")
 }
 
+// paramIndex maps a parameter register of an instance method to the index of the
+// parameter in its signature, p0 being `this` and long/double taking two registers
+func paramIndex(paramsSig, register string) int {
+	n, err := strconv.Atoi(strings.TrimPrefix(register, "p"))
+	if err != nil || !strings.HasPrefix(register, "p") {
+		return -1
+	}
+	params, err := parseDescriptorParams(paramsSig)
+	if err != nil {
+		return -1
+	}
+	reg := 1
+	for i, t := range params {
+		if reg == n {
+			return i
+		}
+		reg++
+		if t.isWide() {
+			reg++
+		}
+	}
+	return -1
+}
+
+// applyKotlinParamNames names the params missing debug info from the kotlin metadata of their class
+func applyKotlinParamNames(methods []SmaliMethod, meta *kotlinClassMetadata) {
+	if meta == nil {
+		return
+	}
+	for i := range methods {
+		m := &methods[i]
+		params, err := parseDescriptorParams(m.ParamsSig)
+		if err != nil {
+			continue
+		}
+		n := len(params)
+		if m.Suspend {
+			// the metadata doesn't count the continuation
+			n--
+		}
+		fn, ok := meta.function(m.Name, n)
+		if !ok {
+			continue
+		}
+		for j := range m.Params {
+			p := &m.Params[j]
+			if p.Name != "" {
+				continue
+			}
+			if idx := paramIndex(m.ParamsSig, p.Register); idx != -1 && idx < len(fn.Params) {
+				p.Name = fn.Params[idx].Name
+			}
+		}
+	}
+}
+
+// typeSignature is the generic type of the param when the method signature gives it, its descriptor otherwise
+func (p SmaliParam) typeSignature() string {
+	if p.Signature != "" {
+		return p.Signature
+	}
+	return p.TypeSig
+}
+
+// displayName is the best name we have for a param that has no retrofit provided name
+func (p SmaliParam) displayName() string {
+	if p.Name != "" {
+		return p.Name
+	}
+	return p.Register
+}
+
+// parseHTTPAnnotation reads the verb, relative path and body flag from
+// @GET/@POST/... or the generic @HTTP(method=..., path=..., hasBody=...)
+func parseHTTPAnnotation(method *SmaliMethod) {
+	for _, a := range methodAnnotations(method.Body) {
+		matches := retrofitHTTPAnnotation.FindStringSubmatch(a.Type)
+		if matches == nil {
+			continue
+		}
+		if matches[1] == "HTTP" {
+			verb, _ := a.String("method")
+			if verb == "" {
+				addDiagnostic("method %s has an @HTTP annotation without a method, skipped", method.Name)
+				return
+			}
+			method.HTTPVerb = strings.ToUpper(verb)
+			method.HTTPPath, _ = a.String("path")
+			method.HasBody = a.Bool("hasBody")
+		} else {
+			method.HTTPVerb = matches[1]
+			method.HTTPPath, _ = a.String("value")
+			method.HasBody = method.HTTPVerb == "POST" || method.HTTPVerb == "PUT" || method.HTTPVerb == "PATCH"
+		}
+		log.Printf("Method %s => %s %s (hasBody=%t)", method.Name, method.HTTPVerb, method.HTTPPath, method.HasBody)
+		return
+	}
+}
+
+// parseEncodingAnnotations reads the request encoding from @FormUrlEncoded or @Multipart
+func parseEncodingAnnotations(method *SmaliMethod) {
+	annotations := methodAnnotations(method.Body)
+	_, method.FormURLEncoded = findAnnotation(annotations, "Lretrofit2/http/FormUrlEncoded;")
+	_, method.Multipart = findAnnotation(annotations, "Lretrofit2/http/Multipart;")
+}
+
+// parseHeadersAnnotation reads the fixed headers from @Headers({"Name: value", ...})
+func parseHeadersAnnotation(method *SmaliMethod) {
+	a, ok := findAnnotation(methodAnnotations(method.Body), "Lretrofit2/http/Headers;")
+	if !ok {
+		return
+	}
+	for _, h := range a.Strings("value") {
+		name, value, ok := strings.Cut(h, ":")
+		if !ok {
+			addDiagnostic("method %s has a malformed @Headers entry %q without a colon, skipped", method.Name, h)
+			continue
+		}
+		method.Headers = append(method.Headers, StaticHeader{
+			Name:  strings.TrimSpace(name),
+			Value: strings.TrimSpace(value),
+		})
+	}
+}
+
+// parseSuspendFunction recognises a kotlin `suspend fun`, compiled with a trailing Continuation
+// param and an Object return. The real return type is the type argument of the continuation,
+// e.g. `Lkotlin/coroutines/Continuation<-Lretrofit2/Response<Lfoo/Bar;>;>;` => Response<Bar>.
+func parseSuspendFunction(method *SmaliMethod) {
+	params, err := parseDescriptorParams(method.ParamsSig)
+	if err != nil || len(params) == 0 || params[len(params)-1].Name != "kotlin/coroutines/Continuation" {
+		return
+	}
+	method.Suspend = true
+	last := len(params) - 1
+	var kept []SmaliParam
+	for _, p := range method.Params {
+		if paramIndex(method.ParamsSig, p.Register) != last {
+			kept = append(kept, p)
+		}
+	}
+	method.Params = kept
+
+	if method.ReturnSignature == "" {
+		log.Printf("Method %s => suspend without signature, return type unknown", method.Name)
+		return
+	}
+	sig, err := parseMethodSignature(method.ReturnSignature)
+	if err != nil || len(sig.Params) != len(params) {
+		log.Printf("Method %s => suspend with unexpected signature %s", method.Name, method.ReturnSignature)
+		return
+	}
+	continuation := sig.Params[last]
+	if len(continuation.Args) != 1 {
+		return
+	}
+	result := continuation.Args[0]
+	if result.Kind == jvmWildcard && result.Elem != nil {
+		result = result.Elem
+	}
+	method.ReturnType = result.descriptor()
+	method.ReturnSignature = result.String()
+	log.Printf("Method %s => suspend returning %s", method.Name, method.ReturnSignature)
+}
+
 func fillRetrofitAnnotations(methods []SmaliMethod) []SmaliMethod {
 	for i := range methods {
 		sm := &methods[i]
-		if matches := retrofitHTTPAnnotation.FindStringSubmatch(sm.Body); matches != nil {
-			sm.HTTPVerb = strings.ToUpper(matches[1])
-			sm.HTTPPath = matches[2]
-			log.Printf("Method %s => %s %s", sm.Name, sm.HTTPVerb, sm.HTTPPath)
-		}
+		parseHTTPAnnotation(sm)
+		parseHeadersAnnotation(sm)
+		parseEncodingAnnotations(sm)
 		parseMethodParams(sm)
 		parseSignatureAnnotation(sm)
+		parseSuspendFunction(sm)
 	}
 	return methods
 }
 
-// parseFieldsFromFile extracts .field lines => map[fieldName] = fieldSig
-func parseFieldsFromFile(filePath string) (map[string]string, error) {
-	log.Printf("parseFieldsFromFile: %s", filePath)
+// parseSmaliFields extracts every .field declaration, in declaration order
+func parseSmaliFields(content string) []SmaliField {
+	var fields []SmaliField
+	for _, idx := range fieldPattern.FindAllStringSubmatchIndex(content, -1) {
+		field := SmaliField{
+			Name:      content[idx[4]:idx[5]],
+			TypeSig:   content[idx[6]:idx[7]],
+			Modifiers: strings.Fields(content[idx[2]:idx[3]]),
+		}
+		if idx[8] != -1 {
+			field.Value = strings.TrimSpace(content[idx[8]:idx[9]])
+		}
+		// annotated fields are a block closed by `.end field`
+		rest := content[idx[1]:]
+		if strings.HasPrefix(strings.TrimSpace(rest), ".annotation") {
+			if end := strings.Index(rest, ".end field"); end != -1 {
+				field.Annotations = parseAnnotations(rest[:end])
+			}
+		}
+		fields = append(fields, field)
+	}
+	return fields
+}
+
+func (f SmaliField) hasModifier(modifier string) bool {
+	for _, m := range f.Modifiers {
+		if m == modifier {
+			return true
+		}
+	}
+	return false
+}
+
+// parseSmaliClass extracts the class header, class level annotations and fields.
+// Kotlin stores property annotations (e.g. kotlinx @SerialName) on synthetic
+// `getFoo$annotations` methods, those are merged into the matching field.
+func parseSmaliClass(content string) *SmaliClass {
+	class := &SmaliClass{}
+	if m := classHeaderPattern.FindStringSubmatch(content); m != nil {
+		class.Modifiers = strings.Fields(m[1])
+		class.Name = m[2]
+	}
+	if m := superPattern.FindStringSubmatch(content); m != nil {
+		class.Super = m[1]
+	}
+	for _, m := range implementsPattern.FindAllStringSubmatch(content, -1) {
+		class.Interfaces = append(class.Interfaces, m[1])
+	}
+
+	// class annotations come before the first field or method
+	header := content
+	if idx := memberStartPattern.FindStringIndex(content); idx != nil {
+		header = content[:idx[0]]
+	}
+	class.Annotations = parseAnnotations(header)
+
+	meta, err := parseKotlinMetadata(class.Annotations)
+	if err != nil {
+		log.Printf("Could not decode kotlin metadata of %s: %v", class.Name, err)
+	}
+	class.Kotlin = meta
+
+	if a, ok := findAnnotation(class.Annotations, "Ldalvik/annotation/Signature;"); ok {
+		sig, err := parseClassSignature(strings.Join(a.Strings("value"), ""))
+		if err != nil {
+			log.Printf("Could not parse the signature of %s: %v", class.Name, err)
+		}
+		class.Signature = sig
+	}
+
+	class.Fields = parseSmaliFields(content)
+	for i := range class.Fields {
+		if name, ok := originalFieldName(class.Name, class.Fields[i].Name); ok {
+			class.Fields[i].OriginalName = name
+		}
+	}
+	for _, m := range parseSmaliMethods(content) {
+		if !strings.HasSuffix(m.Name, "$annotations") {
+			continue
+		}
+		property := kotlinPropertyName(strings.TrimSuffix(m.Name, "$annotations"))
+		for i := range class.Fields {
+			if class.Fields[i].Name == property {
+				class.Fields[i].Annotations = append(class.Fields[i].Annotations, methodAnnotations(m.Body)...)
+			}
+		}
+	}
+	return class
+}
+
+// parseClassFile reads and parses a smali class file
+func parseClassFile(filePath string) (*SmaliClass, error) {
+	log.Printf("parseClassFile: %s", filePath)
 	data, err := os.ReadFile(filePath)
 	if err != nil {
 		return nil, err
 	}
-	content := string(data)
-	matches := fieldPattern.FindAllStringSubmatch(content, -1)
-	log.Printf("Found %d fields in %s", len(matches), filePath)
+	return parseSmaliClass(string(data)), nil
+}
+
+// isEnum reports whether the class is a java/kotlin enum
+func (c *SmaliClass) isEnum() bool {
+	for _, m := range c.Modifiers {
+		if m == "enum" {
+			return true
+		}
+	}
+	return false
+}
+
+// enumConstants returns the enum constant fields, skipping $VALUES and other statics
+func (c *SmaliClass) enumConstants() []SmaliField {
+	var constants []SmaliField
+	for _, f := range c.Fields {
+		if f.hasModifier("static") && f.hasModifier("enum") {
+			constants = append(constants, f)
+		}
+	}
+	return constants
+}
+
+// instanceFields returns the non static fields, in declaration order
+func (c *SmaliClass) instanceFields() []SmaliField {
+	var fields []SmaliField
+	for _, f := range c.Fields {
+		if f.hasModifier("static") || f.hasModifier("synthetic") {
+			continue
+		}
+		log.Printf("  field: %s => %s", f.Name, f.TypeSig)
+		fields = append(fields, f)
+	}
+	return fields
+}
+
+// isFrameworkClass reports whether the class belongs to the platform or a library
+func isFrameworkClass(sig string) bool {
+	for _, pkg := range frameworkPackages {
+		if strings.HasPrefix(sig, pkg) {
+			return true
+		}
+	}
+	return false
+}
+
+// appSuperclass returns the superclass when it is one of the app's own classes, empty otherwise
+func (c *SmaliClass) appSuperclass() string {
+	if c.Super == "" || isFrameworkClass(c.Super) {
+		return ""
+	}
+	if _, ok := classToFilePath[c.Super]; !ok {
+		return ""
+	}
+	return c.Super
+}
+
+// appInterfaces returns the implemented interfaces that are the app's own classes
+func (c *SmaliClass) appInterfaces() []string {
+	var interfaces []string
+	for _, i := range c.Interfaces {
+		if _, ok := classToFilePath[i]; ok && !isFrameworkClass(i) {
+			interfaces = append(interfaces, i)
+		}
+	}
+	return interfaces
+}
+
+// typeBindings maps the type parameters of a generic class to the type arguments it is
+// instantiated with. A raw use binds them to their bounds, nil when the class isn't generic.
+func (c *SmaliClass) typeBindings(args []*jvmType) map[string]*jvmType {
+	if c == nil || c.Signature == nil || len(c.Signature.TypeParams) == 0 {
+		return nil
+	}
+	if len(args) != 0 && len(args) != len(c.Signature.TypeParams) {
+		log.Printf("  %s expects %d type arguments, got %d", c.Name, len(c.Signature.TypeParams), len(args))
+		return nil
+	}
+	bindings := map[string]*jvmType{}
+	for i, p := range c.Signature.TypeParams {
+		switch {
+		case len(args) != 0:
+			bindings[p.Name] = args[i]
+		case len(p.Bounds) > 0 && p.Bounds[0].Name != "java/lang/Object":
+			bindings[p.Name] = p.Bounds[0]
+		}
+	}
+	return bindings
+}
 
-	fields := make(map[string]string)
-	for _, m := range matches {
-		fieldName := m[1]
-		fieldSig := m[2]
-		log.Printf("  field: %s => %s", fieldName, fieldSig)
-		fields[fieldName] = fieldSig
+// kotlinPropertyName maps a getter name to its property, e.g. getUserId => userId, isActive => isActive
+func kotlinPropertyName(getter string) string {
+	if strings.HasPrefix(getter, "get") && len(getter) > 3 {
+		return strings.ToLower(getter[3:4]) + getter[4:]
 	}
-	return fields, nil
+	return getter
 }
 
 // --------------------------------------------------------------------------
@@ -235,47 +720,75 @@ func parseFieldsFromFile(filePath string) (map[string]string, error) {
 func ExtractAPIEndpoints(content string) ([]*APIEndpoint, error) {
 	methods := parseSmaliMethods(content)
 	methods = fillRetrofitAnnotations(methods)
+	class := parseSmaliClass(content)
+	applyKotlinParamNames(methods, class.Kotlin)
 
 	var apis []*APIEndpoint
 	for _, m := range methods {
-		if m.HTTPVerb != "" && m.HTTPPath != "" {
+		dynamicURL := hasURLParam(m.Params)
+		if m.HTTPVerb != "" && (m.HTTPPath != "" || dynamicURL) {
 			log.Printf("Build APIEndpoint for method=%s path=%s verb=%s", m.Name, m.HTTPPath, m.HTTPVerb)
-			apis = append(apis, &APIEndpoint{
+			api := &APIEndpoint{
+				ClassName:       class.Name,
 				Path:            m.HTTPPath,
 				Method:          m.HTTPVerb,
 				MethodName:      m.Name,
 				ReturnType:      m.ReturnType,
+				HasBody:         m.HasBody,
+				FormURLEncoded:  m.FormURLEncoded,
+				Multipart:       m.Multipart,
+				DynamicURL:      dynamicURL,
+				Headers:         m.Headers,
 				Params:          m.Params,
 				ReturnSignature: m.ReturnSignature,
-			})
+			}
+			if name, ok := originalMethodName(class.Name, m.Name, m.ParamsSig); ok {
+				api.ObfuscatedName = m.Name
+				api.MethodName = name
+			}
+			apis = append(apis, api)
 		}
 	}
 	return apis, nil
 }
 
+func hasURLParam(params []SmaliParam) bool {
+	for _, p := range params {
+		if p.Annotation == "Url" {
+			return true
+		}
+	}
+	return false
+}
+
 // --------------------------------------------------------------------------
 // 6) SWAGGER GENERATION
 // --------------------------------------------------------------------------
 
+// HTTP verbs a swagger 2.0 path item has an operation for
+var swaggerVerbs = map[string]bool{
+	"GET": true, "POST": true, "PUT": true, "DELETE": true, "PATCH": true, "HEAD": true, "OPTIONS": true,
+}
+
 func GenerateSwaggerSpec(endpoints []*APIEndpoint) (*swagger.Swagger, error) {
 	log.Printf("Generating Swagger spec from %d endpoints...", len(endpoints))
+	definitionOwners = map[string]string{}
 	spec := &swagger.Swagger{
 		SwaggerProps: swagger.SwaggerProps{
-			Swagger: "2.0",
-			Info: &swagger.Info{
-				InfoProps: swagger.InfoProps{
-					Title:       "Extracted API",
-					Version:     "1.0.0",
-					Description: "API extracted from Smali files",
-				},
-			},
+			Swagger:     "2.0",
+			Info:        buildInfo(),
 			Paths:       &swagger.Paths{Paths: map[string]swagger.PathItem{}},
 			Definitions: map[string]swagger.Schema{},
 		},
 	}
+	applyBaseURLs(spec, endpoints)
+	applyInterceptors(spec)
+	applyOAuth(spec)
 
+	multipleBases := len(distinctBaseURLs()) > 1
+	var dynamicURLOperations, customMethodOperations []map[string]interface{}
 	for _, endpoint := range endpoints {
-		if !strings.HasPrefix(endpoint.Path, "/") {
+		if !endpoint.DynamicURL && !strings.HasPrefix(endpoint.Path, "/") {
 			endpoint.Path = "/" + endpoint.Path
 		}
 
@@ -294,27 +807,54 @@ func GenerateSwaggerSpec(endpoints []*APIEndpoint) (*swagger.Swagger, error) {
 		}
 
 		swaggerParams := buildSwaggerParams(endpoint, spec)
+		swaggerParams = append(swaggerParams, buildStaticHeaderParams(endpoint, swaggerParams)...)
 		operation.Parameters = swaggerParams
+		addMapParamExtensions(operation, endpoint)
+		applyClientParams(operation, endpoint)
+		if endpoint.ObfuscatedName != "" {
+			operation.AddExtension("x-obfuscated-name", endpoint.ObfuscatedName)
+		}
+		if b, ok := interfaceBindings[endpoint.ClassName]; ok {
+			if b.BaseURL != "" {
+				operation.AddExtension("x-base-url", b.BaseURL)
+				if multipleBases {
+					// the path is relative to this base, not necessarily to host and basePath
+					operation.Tags = append(operation.Tags, baseURLTag(spec, b.BaseURL))
+				}
+			}
+			if b.Retrofit != "" {
+				operation.AddExtension("x-retrofit-instance", b.Retrofit)
+			}
+			if b.Client != "" {
+				operation.AddExtension("x-okhttp-client", b.Client)
+			}
+		}
 
-		if hasBody(swaggerParams) {
+		switch {
+		case endpoint.Multipart:
+			operation.Consumes = []string{"multipart/form-data"}
+		case endpoint.FormURLEncoded:
+			operation.Consumes = []string{"application/x-www-form-urlencoded"}
+		case hasBody(swaggerParams):
 			operation.Consumes = []string{"application/json"}
 		}
 
-		if endpoint.ReturnSignature != "" {
-			log.Printf("Endpoint %s => ReturnSignature: %s", endpoint.MethodName, endpoint.ReturnSignature)
+		// non generic return types, e.g. Completable or a raw Call, have no @Signature but are still wrappers
+		returnSig := endpoint.ReturnSignature
+		if returnSig == "" {
+			returnSig = endpoint.ReturnType
+		}
+		if returnSig != "" {
+			log.Printf("Endpoint %s => ReturnSignature: %s", endpoint.MethodName, returnSig)
 
-			kind, itemRef, err := interpretTypeAndBuildDefinition(endpoint.ReturnSignature, spec)
+			schema, err := interpretTypeAndBuildDefinition(returnSig, spec)
 			if err != nil {
 				return nil, err
 			}
-			resp, err := buildResponse(kind, itemRef, spec)
-			if err != nil {
-				return nil, err
-			}
-			log.Printf("Endpoint %s => kind=%s itemRef=%s => response built", endpoint.MethodName, kind, itemRef)
-			operation.Responses.StatusCodeResponses[200] = *resp
+			log.Printf("Endpoint %s => response built", endpoint.MethodName)
+			operation.Responses.StatusCodeResponses[200] = *buildResponse(schema)
 		} else {
-			log.Printf("Endpoint %s => no ReturnSignature => default string response", endpoint.MethodName)
+			log.Printf("Endpoint %s => no return type => default string response", endpoint.MethodName)
 			operation.Responses.StatusCodeResponses[200] = swagger.Response{
 				ResponseProps: swagger.ResponseProps{
 					Description: "OK",
@@ -327,6 +867,22 @@ func GenerateSwaggerSpec(endpoints []*APIEndpoint) (*swagger.Swagger, error) {
 			}
 		}
 
+		if endpoint.DynamicURL {
+			if !swaggerVerbs[strings.ToUpper(endpoint.Method)] {
+				addDiagnostic("endpoint %s uses unsupported HTTP verb %q on a dynamic @Url",
+					endpoint.MethodName, endpoint.Method)
+			}
+			// there is no path to put it under, keep it out of spec.Paths
+			log.Printf("Endpoint %s => dynamic @Url => x-dynamic-url-operations", endpoint.MethodName)
+			operation.AddExtension("x-dynamic-url", true)
+			dynamicURLOperations = append(dynamicURLOperations, map[string]interface{}{
+				"method":    strings.ToUpper(endpoint.Method),
+				"name":      endpoint.MethodName,
+				"operation": operation,
+			})
+			continue
+		}
+
 		switch strings.ToUpper(endpoint.Method) {
 		case "GET":
 			pathItem.Get = operation
@@ -336,17 +892,60 @@ func GenerateSwaggerSpec(endpoints []*APIEndpoint) (*swagger.Swagger, error) {
 			pathItem.Put = operation
 		case "DELETE":
 			pathItem.Delete = operation
+		case "PATCH":
+			pathItem.Patch = operation
+		case "HEAD":
+			pathItem.Head = operation
+		case "OPTIONS":
+			pathItem.Options = operation
 		default:
-			pathItem.Post = operation
+			// swagger 2.0 has no slot for custom verbs, don't disguise them as another verb
+			addDiagnostic("endpoint %s uses unsupported HTTP verb %q on %s, kept in x-custom-method-operations",
+				endpoint.MethodName, endpoint.Method, endpoint.Path)
+			customMethodOperations = append(customMethodOperations, map[string]interface{}{
+				"method":    strings.ToUpper(endpoint.Method),
+				"path":      endpoint.Path,
+				"name":      endpoint.MethodName,
+				"operation": operation,
+			})
+			continue
 		}
 		spec.Paths.Paths[endpoint.Path] = pathItem
 	}
 
+	if len(dynamicURLOperations) > 0 {
+		spec.AddExtension("x-dynamic-url-operations", dynamicURLOperations)
+	}
+	if len(customMethodOperations) > 0 {
+		spec.AddExtension("x-custom-method-operations", customMethodOperations)
+	}
+
+	if err := checkDefinitionRefs(spec); err != nil {
+		return nil, err
+	}
 	return spec, nil
 }
 
-func buildParamSchema(kind, itemRef string, spec *swagger.Swagger) (*swagger.Schema, error) {
-	return buildPropertySchema(kind, itemRef)
+// Regex for a reference to a definition in the marshalled spec, e.g. "$ref":"#/definitions/GeoPoint"
+var definitionRefPattern = regexp.MustCompile(`"\$ref":"#/definitions/([^"]+)"`)
+
+// checkDefinitionRefs reports the references to definitions the spec doesn't have,
+// e.g. a type mapping with a ref to a definition nothing generates
+func checkDefinitionRefs(spec *swagger.Swagger) error {
+	data, err := json.Marshal(spec)
+	if err != nil {
+		return fmt.Errorf("checking definition references: %w", err)
+	}
+	reported := map[string]bool{}
+	for _, m := range definitionRefPattern.FindAllStringSubmatch(string(data), -1) {
+		name := m[1]
+		if _, ok := spec.Definitions[name]; ok || reported[name] {
+			continue
+		}
+		reported[name] = true
+		addDiagnostic("reference to definition %s, which the spec doesn't have", name)
+	}
+	return nil
 }
 
 func buildSwaggerParams(endpoint *APIEndpoint, spec *swagger.Swagger) []swagger.Parameter {
@@ -357,95 +956,230 @@ func buildSwaggerParams(endpoint *APIEndpoint, spec *swagger.Swagger) []swagger.
 		runtime.Breakpoint()
 	}
 	var params []swagger.Parameter
+	unnamedParts, queryNames := 0, 0
 	for _, p := range endpoint.Params {
-		log.Printf("  param register=%s typeSig=%s pathVar=%s queryVar=%s",
-			p.Register, p.TypeSig, p.PathVar, p.QueryVar)
+		log.Printf("  param register=%s typeSig=%s annotation=%s pathVar=%s queryVar=%s headerVar=%s",
+			p.Register, p.TypeSig, p.Annotation, p.PathVar, p.QueryVar, p.HeaderVar)
 
+		switch {
 		// 1) If we have a path variable => create path param
-		if p.PathVar != "" {
-			paramType := smaliTypeToSwaggerType(p.TypeSig)
+		case p.PathVar != "":
 			sp := swagger.Parameter{
 				ParamProps: swagger.ParamProps{
 					Name:     p.PathVar,
 					In:       "path",
 					Required: true,
 				},
-				SimpleSchema: swagger.SimpleSchema{
-					Type: paramType,
-				},
+				SimpleSchema: simpleSchema(p.typeSignature(), "csv"),
 			}
 			params = append(params, sp)
-		}
 
 		// 2) If we have a query variable => create query param
-		if p.QueryVar != "" {
-			paramType := smaliTypeToSwaggerType(p.TypeSig)
+		case p.QueryVar != "":
 			sp := swagger.Parameter{
 				ParamProps: swagger.ParamProps{
 					Name: p.QueryVar,
 					In:   "query",
 				},
+				SimpleSchema: simpleSchema(p.typeSignature(), "multi"),
+			}
+			params = append(params, sp)
+
+		// 3) If we have a header variable => create header param
+		case p.HeaderVar != "":
+			sp := swagger.Parameter{
+				ParamProps: swagger.ParamProps{
+					Name: p.HeaderVar,
+					In:   "header",
+				},
+				SimpleSchema: simpleSchema(p.typeSignature(), "csv"),
+			}
+			params = append(params, sp)
+
+		// 4) If we have a form field => create formData param
+		case p.FieldVar != "":
+			sp := swagger.Parameter{
+				ParamProps: swagger.ParamProps{
+					Name: p.FieldVar,
+					In:   "formData",
+				},
+				SimpleSchema: simpleSchema(p.typeSignature(), "multi"),
+			}
+			params = append(params, sp)
+
+		// 5) If we have a multipart part => create formData param, raw bodies become files
+		case p.Annotation == "Part":
+			name, description := p.PartVar, ""
+			if name == "" {
+				// MultipartBody.Part carries its own name at runtime
+				unnamedParts++
+				name = fmt.Sprintf("part%d", unnamedParts)
+				description = "The part name is set at runtime by the MultipartBody.Part"
+				addDiagnostic("endpoint %s: @Part %s names itself at runtime, documented as %s",
+					endpoint.MethodName, p.displayName(), name)
+			}
+			paramType := smaliTypeToSwaggerType(p.TypeSig)
+			if isFilePart(p.TypeSig) {
+				paramType = "file"
+			}
+			sp := swagger.Parameter{
+				ParamProps: swagger.ParamProps{
+					Description: description,
+					Name:        name,
+					In:          "formData",
+				},
 				SimpleSchema: swagger.SimpleSchema{
 					Type: paramType,
 				},
 			}
 			params = append(params, sp)
-		}
 
-		// 3) If we have neither path nor query...
-		if p.PathVar == "" && p.QueryVar == "" {
-			// ...and method is POST/PUT => treat as body param
-			if methodUpper == "POST" || methodUpper == "PUT" {
-				if isObjectType(p.TypeSig) {
-					log.Printf("    param %s => isObject => body param", p.Register)
-					k, itemRef, err := interpretTypeAndBuildDefinition(p.TypeSig, spec)
-					if err != nil {
-						log.Printf("Error interpreting body param: %v", err)
-						k = "object"
-						itemRef = ""
-					}
-					paramSchema, err := buildParamSchema(k, itemRef, spec)
-					if err != nil {
-						log.Printf("Error building schema for body param: %v", err)
-						paramSchema = &swagger.Schema{
-							SchemaProps: swagger.SchemaProps{Type: []string{"object"}},
-						}
-					}
-					sp := swagger.Parameter{
-						ParamProps: swagger.ParamProps{
-							Name:     "body",
-							In:       "body",
-							Required: true,
-							Schema:   paramSchema,
-						},
-					}
-					params = append(params, sp)
-				} else {
-					// If it's a primitive in a POST/PUT with no annotation,
-					// you could treat it as a query param or skip. Let's do query:
-					t := smaliTypeToSwaggerType(p.TypeSig)
-					log.Printf("    param %s => prim => fallback query with type=%s", p.Register, t)
-					sp := swagger.Parameter{
-						ParamProps: swagger.ParamProps{
-							Name: p.Register,
-							In:   "query",
-						},
-						SimpleSchema: swagger.SimpleSchema{
-							Type: t,
-						},
-					}
-					params = append(params, sp)
-				}
-			} else {
-				// If method is GET/DELETE and we have no path/query => skip
-				// This prevents "result" parameters from showing up in "query"
-				log.Printf("    param %s => skipping for %s method", p.Register, methodUpper)
+		// 6) @QueryName => a query param that is only a name, without a value
+		case p.Annotation == "QueryName":
+			// the value is the name, there is none to document
+			queryNames++
+			name := "queryName"
+			if queryNames > 1 {
+				name = fmt.Sprintf("queryName%d", queryNames)
 			}
+			sp := swagger.Parameter{
+				ParamProps: swagger.ParamProps{
+					Description:     "Query parameter names sent without a value (@QueryName), each value is a name",
+					Name:            name,
+					In:              "query",
+					AllowEmptyValue: true,
+				},
+				SimpleSchema: simpleSchema(p.typeSignature(), "multi"),
+			}
+			params = append(params, sp)
+
+		// 7) @Body => body param on any verb
+		case p.Annotation == "Body":
+			if !endpoint.HasBody {
+				// retrofit refuses it: "Non-body HTTP method cannot contain @Body"
+				addDiagnostic("endpoint %s has a @Body param on %s which has no body, skipped",
+					endpoint.MethodName, methodUpper)
+				continue
+			}
+			log.Printf("    param %s => body param", p.Register)
+			paramSchema, err := interpretTypeAndBuildDefinition(p.TypeSig, spec)
+			if err != nil {
+				log.Printf("Error interpreting body param: %v", err)
+				paramSchema = nil
+			}
+			if paramSchema == nil {
+				paramSchema = primitiveSchema("object")
+			}
+			sp := swagger.Parameter{
+				ParamProps: swagger.ParamProps{
+					Name:     "body",
+					In:       "body",
+					Required: true,
+					Schema:   paramSchema,
+				},
+			}
+			params = append(params, sp)
+
+		// 8) map style params are documented on the operation, see addMapParamExtensions,
+		// and @Url is handled by GenerateSwaggerSpec
+		case p.Annotation == "HeaderMap", p.Annotation == "FieldMap", p.Annotation == "PartMap",
+			p.Annotation == "QueryMap", p.Annotation == "Url":
+			log.Printf("    param %s => %s", p.Register, p.Annotation)
+
+		// 9) No retrofit annotation we understand => skip
+		default:
+			log.Printf("    param %s => no retrofit annotation, skipping for %s method", p.Register, methodUpper)
 		}
 	}
 	return params
 }
 
+// buildStaticHeaderParams turns each @Headers entry into a required header param fixed to its value,
+// unless the method already has a param for that header, e.g. a @Header("X-Tenant-Id") next to
+// @Headers("X-Tenant-Id: default")
+func buildStaticHeaderParams(endpoint *APIEndpoint, declared []swagger.Parameter) []swagger.Parameter {
+	var params []swagger.Parameter
+	for _, h := range endpoint.Headers {
+		if hasParam(declared, h.Name, "header") || hasParam(params, h.Name, "header") {
+			log.Printf("  static header %s is already a param of %s, skipped", h.Name, endpoint.MethodName)
+			continue
+		}
+		params = append(params, swagger.Parameter{
+			ParamProps: swagger.ParamProps{
+				Name:     h.Name,
+				In:       "header",
+				Required: true,
+			},
+			SimpleSchema: swagger.SimpleSchema{
+				Type:    "string",
+				Default: h.Value,
+			},
+			CommonValidations: swagger.CommonValidations{
+				Enum: []interface{}{h.Value},
+			},
+		})
+	}
+	return params
+}
+
+// isFilePart reports whether a multipart part is sent as raw content rather than a value
+func isFilePart(sig string) bool {
+	switch strings.TrimSpace(sig) {
+	case "Lokhttp3/MultipartBody$Part;", "Lokhttp3/RequestBody;":
+		return true
+	}
+	return false
+}
+
+// mapParamExtensions describes how each map style annotation is documented by addMapParamExtensions
+var mapParamExtensions = map[string]struct {
+	key         string
+	description string
+	values      swagger.SchemaProps
+}{
+	"HeaderMap": {
+		key:         "x-header-map",
+		description: "Arbitrary request headers supplied at runtime (@HeaderMap)",
+		values:      swagger.SchemaProps{Type: []string{"string"}},
+	},
+	"FieldMap": {
+		key:         "x-field-map",
+		description: "Arbitrary form fields supplied at runtime (@FieldMap)",
+		values:      swagger.SchemaProps{Type: []string{"string"}},
+	},
+	"PartMap": {
+		key:         "x-part-map",
+		description: "Arbitrary multipart parts supplied at runtime (@PartMap)",
+		values:      swagger.SchemaProps{Type: []string{"string"}, Format: "binary"},
+	},
+	"QueryMap": {
+		key:         "x-query-map",
+		description: "Arbitrary query parameters supplied at runtime (@QueryMap)",
+		values:      swagger.SchemaProps{Type: []string{"string"}},
+	},
+}
+
+// addMapParamExtensions documents map style params (e.g. @HeaderMap), which swagger 2.0
+// can't express as parameters, as a free-form object under a vendor extension
+func addMapParamExtensions(operation *swagger.Operation, endpoint *APIEndpoint) {
+	for _, p := range endpoint.Params {
+		ext, ok := mapParamExtensions[p.Annotation]
+		if !ok {
+			continue
+		}
+		operation.AddExtension(ext.key, swagger.Schema{
+			SchemaProps: swagger.SchemaProps{
+				Description: ext.description,
+				Type:        []string{"object"},
+				AdditionalProperties: &swagger.SchemaOrBool{
+					Allows: true,
+					Schema: &swagger.Schema{SchemaProps: ext.values},
+				},
+			},
+		})
+	}
+}
+
 func hasBody(params []swagger.Parameter) bool {
 	for _, p := range params {
 		if p.In == "body" {
@@ -455,469 +1189,504 @@ func hasBody(params []swagger.Parameter) bool {
 	return false
 }
 
-func isObjectType(sig string) bool {
-	sig = strings.TrimSpace(sig)
-	return strings.HasPrefix(sig, "L") && strings.HasSuffix(sig, ";")
+// builtinType is the swagger type and format of a primitive or a well known value class
+type builtinType struct {
+	typ    string
+	format string
 }
 
-func smaliTypeToSwaggerType(sig string) string {
-	sig = strings.TrimSpace(sig)
-	switch sig {
-	case "J":
-		return "integer"
-	case "I", "Ljava/lang/Integer;":
-		return "integer"
-	case "Z", "Ljava/lang/Boolean;":
-		return "boolean"
-	case "F", "D":
-		return "number"
-	case "B", "S":
-		return "integer"
-	case "Ljava/lang/String;":
-		return "string"
-	default:
-		if isObjectType(sig) {
-			if strings.Contains(sig, "String;") {
-				return "string"
-			}
-			//return "object"
-		}
-		return "string"
-	}
+// builtinTypes maps primitives and platform value classes by descriptor, for properties,
+// responses and params, see simpleSchema for the dates params can't keep the format of
+var builtinTypes = map[string]builtinType{
+	"I":                                {"integer", "int32"},
+	"S":                                {"integer", "int32"},
+	"B":                                {"integer", "int32"},
+	"J":                                {"integer", "int64"},
+	"F":                                {"number", "float"},
+	"D":                                {"number", "double"},
+	"Z":                                {"boolean", ""},
+	"C":                                {"string", ""},
+	"Ljava/lang/Integer;":              {"integer", "int32"},
+	"Ljava/lang/Short;":                {"integer", "int32"},
+	"Ljava/lang/Byte;":                 {"integer", "int32"},
+	"Ljava/lang/Long;":                 {"integer", "int64"},
+	"Ljava/lang/Float;":                {"number", "float"},
+	"Ljava/lang/Double;":               {"number", "double"},
+	"Ljava/lang/Number;":               {"number", ""},
+	"Ljava/lang/Boolean;":              {"boolean", ""},
+	"Ljava/lang/Character;":            {"string", ""},
+	"Ljava/lang/String;":               {"string", ""},
+	"Ljava/lang/CharSequence;":         {"string", ""},
+	"Ljava/math/BigDecimal;":           {"number", "decimal"},
+	"Ljava/math/BigInteger;":           {"integer", ""},
+	"Ljava/util/UUID;":                 {"string", "uuid"},
+	"Ljava/util/Date;":                 {"string", "date-time"},
+	"Ljava/time/Instant;":              {"string", "date-time"},
+	"Ljava/time/OffsetDateTime;":       {"string", "date-time"},
+	"Ljava/time/ZonedDateTime;":        {"string", "date-time"},
+	"Ljava/time/LocalDateTime;":        {"string", "date-time"},
+	"Ljava/time/LocalDate;":            {"string", "date"},
+	"Lorg/threeten/bp/Instant;":        {"string", "date-time"},
+	"Lorg/threeten/bp/OffsetDateTime;": {"string", "date-time"},
+	"Lorg/threeten/bp/ZonedDateTime;":  {"string", "date-time"},
+	"Lorg/threeten/bp/LocalDateTime;":  {"string", "date-time"},
+	"Lorg/threeten/bp/LocalDate;":      {"string", "date"},
+	"Lkotlinx/datetime/Instant;":       {"string", "date-time"},
+	"Lkotlinx/datetime/LocalDateTime;": {"string", "date-time"},
+	"Lkotlinx/datetime/LocalDate;":     {"string", "date"},
+	// anything at all
+	"Ljava/lang/Object;": {"object", ""},
+	"Lkotlin/Any;":       {"object", ""},
 }
 
-// genericWrapper describes how to handle "wrapper" types like Observable<T>, List<T>, etc.
-// prefix: the signature prefix we match (e.g. "Lio/reactivex/rxjava3/core/Observable<")
-// parseInside: a function that handles the substring inside < ... >
-//
-//	returning the final (kind, itemRef, error).
-type genericWrapper struct {
-	prefix      string
-	parseInside func(inside string, spec *swagger.Swagger) (string, string, error)
+// toStringDates are the dates whose toString() is not ISO 8601, e.g. "Tue Mar 05 10:00:00 GMT 2024"
+// for a Date or "2024-03-05 10:00:00.0" for a Timestamp, which retrofit sends for a param.
+var toStringDates = map[string]bool{
+	"Ljava/util/Date;":     true,
+	"Ljava/sql/Date;":      true,
+	"Ljava/sql/Timestamp;": true,
+	"Ljava/sql/Time;":      true,
 }
 
-func genericWrapperFunc(preifx, kindOverride string) *genericWrapper {
-	return &genericWrapper{
-		prefix: preifx,
-		parseInside: func(inside string, spec *swagger.Swagger) (string, string, error) {
-			// same logic as List<T>
-			kind, iRef, err := interpretTypeAndBuildDefinition(inside, spec)
-			if err != nil {
-				return "", "", err
-			}
-			if kindOverride != "" {
-				return kindOverride, iRef, nil
+// simpleSchema is the type of a non body param. Arrays and collections (List<Long>, Set<String>, ...)
+// get typed items and are sent in collectionFormat, e.g. "multi" as retrofit repeats @Query and
+// @Field for each element.
+func simpleSchema(sig, collectionFormat string) swagger.SimpleSchema {
+	sig = strings.TrimSpace(sig)
+	if strings.HasPrefix(sig, "L") {
+		if t, err := parseTypeSignature(sig); err == nil && t.Kind == jvmClass {
+			if kind, ok := wrapperRegistry[t.Name]; ok && (kind == ArrayWrapper || kind == SetWrapper) {
+				items := swagger.SimpleSchema{Type: "string"}
+				if len(t.Args) == 1 {
+					if arg := t.Args[0]; arg.Kind != jvmWildcard || arg.Elem != nil {
+						if arg.Kind == jvmWildcard {
+							arg = arg.Elem
+						}
+						// nested collections can't repeat, their elements are joined
+						items = simpleSchema(arg.String(), "csv")
+					}
+				}
+				return swagger.SimpleSchema{
+					Type:             "array",
+					Items:            &swagger.Items{SimpleSchema: items},
+					CollectionFormat: collectionFormat,
+				}
 			}
-			return kind, iRef, nil
-		},
+			sig = t.descriptor()
+		}
+	}
+	switch {
+	case sig == "[B":
+		return swagger.SimpleSchema{Type: "string", Format: "byte"}
+	case strings.HasPrefix(sig, "["):
+		// nested arrays can't repeat, their elements are joined
+		items := simpleSchema(sig[1:], "csv")
+		return swagger.SimpleSchema{
+			Type:             "array",
+			Items:            &swagger.Items{SimpleSchema: items},
+			CollectionFormat: collectionFormat,
+		}
+	}
+	if strings.HasPrefix(sig, "L") && strings.HasSuffix(sig, ";") {
+		if m, ok := findTypeMapping(sig[1 : len(sig)-1]); ok && m.Type != "" && m.Type != "object" {
+			return swagger.SimpleSchema{Type: m.Type, Format: m.Format}
+		}
 	}
+	if toStringDates[sig] {
+		return swagger.SimpleSchema{Type: "string"}
+	}
+	if b, ok := builtinTypes[sig]; ok && b.typ != "object" {
+		return swagger.SimpleSchema{Type: b.typ, Format: b.format}
+	}
+	// retrofit sends everything else through toString()
+	return swagger.SimpleSchema{Type: "string"}
 }
 
-// For example, handle "Observable<T>" by ignoring the wrapper and returning whatever T is.
-var wrapperHandlers []*genericWrapper
-
-func init() {
-	wrapperHandlers = []*genericWrapper{
-		// For Observable<T>, we skip the wrapper, interpret T directly
-		genericWrapperFunc("Lio/reactivex/rxjava3/core/Observable<", ""),
-		genericWrapperFunc("Lretrofit2/Call<", ""),
-		genericWrapperFunc("Lretrofit2/Response<", ""),
-		genericWrapperFunc("Lio/reactivex/rxjava3/core/Single<", ""),
-		genericWrapperFunc("Ljava/util/List<", "array"),
-		genericWrapperFunc("Ljava/util/ArrayList<", "array"),
-		{
-			prefix: "Ljava/util/HashMap<",
-			parseInside: func(inside string, spec *swagger.Swagger) (string, string, error) {
-				// HashMap<K,V> => interpret V => "map", itemRef=...
-				parts := splitSmaliTypes(inside)
-				if len(parts) == 2 {
-					_, valRef, err := interpretTypeAndBuildDefinition(parts[1], spec)
-					if err != nil {
-						return "", "", err
-					}
-					return "map", valRef, nil
-				}
-				// fallback => just "map"
-				return "map", "object", nil
-			},
-		},
-	}
+func smaliTypeToSwaggerType(sig string) string {
+	return simpleSchema(sig, "").Type
 }
 
 // --------------------------------------------------------------------------
 // 7) interpret & build definitions automatically
 // --------------------------------------------------------------------------
 
-func interpretTypeAndBuildDefinition(sig string, spec *swagger.Swagger) (string, string, error) {
+// interpretTypeAndBuildDefinition builds the schema of a type or method signature (taking its
+// return type), adding definitions for the app's classes along the way. A nil schema means void.
+func interpretTypeAndBuildDefinition(sig string, spec *swagger.Swagger) (*swagger.Schema, error) {
 	sig = strings.TrimSpace(sig)
 	log.Printf("interpretTypeAndBuildDefinition sig=%s", sig)
 
-	// skip method param portion
-	if strings.HasPrefix(sig, "(") {
-		if closeIdx := strings.Index(sig, ")"); closeIdx != -1 {
-			sig = strings.TrimSpace(sig[closeIdx+1:])
-			log.Printf("  after dropping method params => %s", sig)
-		}
-	}
-
-	// 1) Check our generic wrapper list first
-	for _, wh := range wrapperHandlers {
-		if strings.HasPrefix(sig, wh.prefix) {
-			log.Printf("  recognized wrapper prefix = %s", wh.prefix)
-			// e.g. "Lio/reactivex/rxjava3/core/Observable<..."
-			open := strings.Index(sig, "<")
-			if open == -1 {
-				// malformed => fallback
-				return "object", "", nil
-			}
-			inside := sig[open+1:]
-			inside = strings.TrimSuffix(inside, ">;")
-			inside = strings.TrimSpace(inside)
-			log.Printf("  inside=%s => pass to parseInside", inside)
-			return wh.parseInside(inside, spec)
-		}
-	}
-
-	// 4) If it's one of the known java.lang wrappers
-	switch sig {
-	case "Ljava/lang/String;":
-		log.Printf("  recognized as built-in String => string")
-		return "string", "", nil
-	case "Ljava/lang/Boolean;":
-		log.Printf("  recognized as built-in Boolean => boolean")
-		return "boolean", "", nil
-	case "Ljava/lang/Integer;", "Ljava/lang/Long;":
-		log.Printf("  recognized as built-in Integer/Long => integer")
-		return "integer", "", nil
-	case "Ljava/lang/Float;", "Ljava/lang/Double;":
-		log.Printf("  recognized as built-in Float/Double => number")
-		return "number", "", nil
-	case "Ljava/lang/Void;", "Lretrofit2/Response<Ljava/lang/Void;>;":
-		log.Printf("  recognized as void => no content")
-		return "void", "", nil
-	case "Ljava/util/Map;":
-		log.Printf("  recognized as built-in Map => map")
-		return "map", "object", nil
-	}
-
-	// 5) If it's an object => parse the smali to build a real definition
-	if strings.HasPrefix(sig, "L") {
-		if !strings.HasSuffix(sig, ";") {
-			sig += ";"
-		}
-		if parsedTypes[sig] {
-			log.Printf("  already parsed type => %s", sig)
-		} else {
-			log.Printf("  parse new object => %s", sig)
-			parsedTypes[sig] = true
-			filePath, ok := classToFilePath[sig]
-			shortName := typeShortName(sig)
-			if !ok {
-				log.Printf("  no file found => minimal def => %s", shortName)
-				if _, found := spec.Definitions[shortName]; !found {
-					spec.Definitions[shortName] = swagger.Schema{
-						SchemaProps: swagger.SchemaProps{
-							Type:       []string{"object"},
-							Properties: map[string]swagger.Schema{},
-						},
-					}
-				}
-				return "object", shortName, nil
-			}
-
-			fieldsMap, err := parseFieldsFromFile(filePath)
-			if err != nil {
-				log.Printf("  parseFieldsFromFile failed => minimal def => %s", shortName)
-				spec.Definitions[shortName] = swagger.Schema{
-					SchemaProps: swagger.SchemaProps{
-						Type:       []string{"object"},
-						Properties: map[string]swagger.Schema{},
-					},
-				}
-				return "object", shortName, nil
-			}
-			log.Printf("  building schema with %d fields => %s", len(fieldsMap), shortName)
-			schemaProps := map[string]swagger.Schema{}
-			for fieldName, fieldSig := range fieldsMap {
-				k, iRef, err := interpretTypeAndBuildDefinition(fieldSig, spec)
-				if err != nil {
-					return "", "", fmt.Errorf("interpretTypeAndBuildDefinition: %w", err)
-				}
-				b, err := buildPropertySchema(k, iRef)
-				if err != nil {
-					return "", "", fmt.Errorf("buildPropertySchema: %w", err)
-				}
-				schemaProps[fieldName] = *b
-			}
-			spec.Definitions[shortName] = swagger.Schema{
-				SchemaProps: swagger.SchemaProps{
-					Type:       []string{"object"},
-					Properties: schemaProps,
-				},
-			}
-		}
-		return "object", typeShortName(sig), nil
-	}
-
-	// 5) If it's known primitive
-	switch sig {
-	case "V":
-		log.Printf("  recognized as void => no content")
-		return "void", "", nil
-	case "I":
-		return "integer", "", nil
-	case "Z":
-		return "boolean", "", nil
-	case "F", "D":
-		return "number", "", nil
-	case "B", "S":
-		return "integer", "", nil
-	}
-
-	log.Printf("  fallback => string => %s", sig)
-	return "string", "", nil
-}
-
-func splitSmaliTypes(s string) []string {
-	s = strings.TrimSpace(s)
-	var result []string
-	i := 0
-	for i < len(s) {
-		c := s[i]
-		switch c {
-		case 'L':
-			end := strings.IndexRune(s[i:], ';')
-			if end == -1 {
-				result = append(result, s[i:])
-				i = len(s)
-			} else {
-				part := s[i : i+end+1]
-				result = append(result, part)
-				i += end + 1
-			}
-		default:
-			result = append(result, string(c))
-			i++
+	var t *jvmType
+	var err error
+	if strings.HasPrefix(sig, "(") || strings.HasPrefix(sig, "<") {
+		var m *jvmMethodSignature
+		if m, err = parseMethodSignature(sig); err == nil {
+			t = m.Return
 		}
+	} else {
+		t, err = parseTypeSignature(sig)
 	}
-	return result
+	if err != nil {
+		addDiagnostic("could not parse type signature: %v, using string", err)
+		return primitiveSchema("string"), nil
+	}
+	return schemaForType(t, spec)
 }
 
-func buildResponse(kind string, itemRef string, spec *swagger.Swagger) (*swagger.Response, error) {
-	log.Printf("buildResponse => kind=%s itemRef=%s", kind, itemRef)
-	desc := "OK"
-	switch kind {
-	case "void":
-		return &swagger.Response{
-			ResponseProps: swagger.ResponseProps{
-				Description: desc,
-			},
-		}, nil
-	case "string", "integer", "boolean", "number":
-		return &swagger.Response{
-			ResponseProps: swagger.ResponseProps{
-				Description: desc,
-				Schema: &swagger.Schema{
-					SchemaProps: swagger.SchemaProps{
-						Type: []string{kind},
-					},
-				},
-			},
-		}, nil
-	case "array":
-		if itemRef == "" {
-			return &swagger.Response{
-				ResponseProps: swagger.ResponseProps{
-					Description: desc,
-					Schema: &swagger.Schema{
-						SchemaProps: swagger.SchemaProps{
-							Type: []string{"array"},
-							Items: &swagger.SchemaOrArray{
-								Schema: &swagger.Schema{
-									SchemaProps: swagger.SchemaProps{
-										Type: []string{"string"},
-									},
-								},
-							},
-						},
-					},
-				},
-			}, nil
+// schemaForType walks a parsed signature, nil meaning void
+func schemaForType(t *jvmType, spec *swagger.Swagger) (*swagger.Schema, error) {
+	switch t.Kind {
+	case jvmPrimitive:
+		if t.Name == "V" {
+			log.Printf("  recognized as void => no content")
+			return nil, nil
 		}
-		ref, err := swagger.NewRef("#/definitions/" + itemRef)
-		if err != nil {
-			return nil, fmt.Errorf("error building ref: %w", err)
-		}
-		return &swagger.Response{
-			ResponseProps: swagger.ResponseProps{
-				Description: desc,
-				Schema: &swagger.Schema{
-					SchemaProps: swagger.SchemaProps{
-						Type: []string{"array"},
-						Items: &swagger.SchemaOrArray{
-							Schema: &swagger.Schema{
-								SchemaProps: swagger.SchemaProps{
-									Ref: ref,
-								},
-							},
-						},
-					},
-				},
-			},
-		}, nil
-	case "map":
-		return &swagger.Response{
-			ResponseProps: swagger.ResponseProps{
-				Description: desc,
-				Schema: &swagger.Schema{
-					SchemaProps: swagger.SchemaProps{
-						Type: []string{"object"},
-						AdditionalProperties: &swagger.SchemaOrBool{
-							Allows: true,
-							Schema: &swagger.Schema{
-								SchemaProps: swagger.SchemaProps{
-									Type: []string{itemRef},
-								},
-							},
-						},
-					},
-				},
-			},
-		}, nil
-	case "object":
-		if itemRef == "" {
-			return &swagger.Response{
-				ResponseProps: swagger.ResponseProps{
-					Description: desc,
-					Schema: &swagger.Schema{
-						SchemaProps: swagger.SchemaProps{
-							Type: []string{"object"},
-						},
-					},
-				},
-			}, nil
+		if b, ok := builtinTypes[t.Name]; ok {
+			return builtinSchema(b), nil
 		}
-		if _, ok := spec.Definitions[itemRef]; !ok {
-			spec.Definitions[itemRef] = swagger.Schema{
+	case jvmArray:
+		if t.Elem.Kind == jvmPrimitive && t.Elem.Name == "B" {
+			// byte[] goes over the wire base64 encoded
+			return &swagger.Schema{
 				SchemaProps: swagger.SchemaProps{
-					Type:       []string{"object"},
-					Properties: map[string]swagger.Schema{},
+					Type:   []string{"string"},
+					Format: "byte",
 				},
-			}
+			}, nil
 		}
-		ref, err := swagger.NewRef("#/definitions/" + itemRef)
+		items, err := schemaForType(t.Elem, spec)
 		if err != nil {
-			return nil, fmt.Errorf("error building ref: %w", err)
-		}
-		return &swagger.Response{
-			ResponseProps: swagger.ResponseProps{
-				Description: desc,
-				Schema: &swagger.Schema{
-					SchemaProps: swagger.SchemaProps{
-						Ref: ref,
-					},
-				},
-			},
-		}, nil
-	default:
-		log.Printf("  fallback => string response")
-		return &swagger.Response{
-			ResponseProps: swagger.ResponseProps{
-				Description: desc,
-				Schema: &swagger.Schema{
-					SchemaProps: swagger.SchemaProps{
-						Type: []string{"string"},
-					},
-				},
+			return nil, err
+		}
+		return &swagger.Schema{
+			SchemaProps: swagger.SchemaProps{
+				Type:  []string{"array"},
+				Items: &swagger.SchemaOrArray{Schema: items},
 			},
 		}, nil
+	case jvmTypeVar:
+		// nothing was substituted for it, e.g. the T of a method level <T> or a raw Envelope
+		log.Printf("  unbound type variable %s => object", t.Name)
+		return primitiveSchema("object"), nil
+	case jvmWildcard:
+		if t.Elem == nil {
+			// <*> => anything
+			return primitiveSchema("object"), nil
+		}
+		// <? extends Foo> and <? super Foo> are both Foo on the wire
+		return schemaForType(t.Elem, spec)
+	case jvmClass:
+		if m, ok := findTypeMapping(t.Name); ok {
+			log.Printf("  %s mapped by %s", t.Name, m.Match)
+			return m.schema()
+		}
+		if kind, ok := wrapperRegistry[t.Name]; ok {
+			log.Printf("  recognized wrapper = %s", t.Name)
+			return wrapperSchema(kind, t, spec)
+		}
+		if t.Name == "java/lang/Void" || t.Name == "kotlin/Unit" {
+			log.Printf("  recognized as void => no content")
+			return nil, nil
+		}
+		if b, ok := builtinTypes[t.descriptor()]; ok {
+			log.Printf("  recognized as built-in %s => %s %s", t.Name, b.typ, b.format)
+			return builtinSchema(b), nil
+		}
+		return buildObjectDefinition(t, spec)
 	}
+
+	log.Printf("  fallback => string => %s", t)
+	return primitiveSchema("string"), nil
 }
 
-func buildPropertySchema(kind, itemRef string) (*swagger.Schema, error) {
-	log.Printf("buildPropertySchema => kind=%s itemRef=%s", kind, itemRef)
-	switch kind {
-	case "string", "integer", "boolean", "number":
-		return &swagger.Schema{
+// buildObjectDefinition parses the smali of a class to build a real definition and returns a ref to it
+func buildObjectDefinition(t *jvmType, spec *swagger.Swagger) (*swagger.Schema, error) {
+	sig := t.descriptor()
+	name, typ := typeShortName(sig), sig
+	if len(t.Args) > 0 {
+		// each instantiation of a generic class gets its own definition, e.g. Envelope_FeatureStatus
+		name, typ = definitionName(t), t.String()
+	}
+	shortName, found := claimDefinitionName(spec, name, typ)
+	// definitions of the spec double as the record of parsed types, the placeholder
+	// stops recursion loops on self referencing models
+	if found {
+		log.Printf("  already parsed type => %s", shortName)
+		return refSchema(shortName)
+	}
+
+	var class *SmaliClass
+	if filePath, ok := classToFilePath[sig]; ok {
+		var err error
+		if class, err = parseClassFile(filePath); err != nil {
+			log.Printf("  parseClassFile failed => %v", err)
+			class = nil
+		}
+	}
+	bindings := class.typeBindings(t.Args)
+	if len(t.Args) > 0 && bindings == nil {
+		// type arguments we can't substitute into anything => plain definition
+		if shortName, found = claimDefinitionName(spec, typeShortName(sig), sig); found {
+			log.Printf("  already parsed type => %s", shortName)
+			return refSchema(shortName)
+		}
+	}
+	log.Printf("  parse new object => %s", shortName)
+	spec.Definitions[shortName] = emptyObjectDefinition()
+	if class == nil {
+		log.Printf("  no class file => minimal def => %s", shortName)
+		return refSchema(shortName)
+	}
+	if class.isEnum() {
+		// enums go over the wire as the (serialized) name of the constant
+		reader := serializerFor(class)
+		var values []interface{}
+		for _, c := range class.enumConstants() {
+			values = append(values, reader.ReadField(class, c).Name)
+		}
+		log.Printf("  enum with %d constants => %s", len(values), shortName)
+		def := swagger.Schema{
 			SchemaProps: swagger.SchemaProps{
-				Type: []string{kind},
+				Type: []string{"string"},
+				Enum: values,
 			},
-		}, nil
-	case "array":
-		if itemRef == "" {
-			return &swagger.Schema{
-				SchemaProps: swagger.SchemaProps{
-					Type: []string{"array"},
-					Items: &swagger.SchemaOrArray{
-						Schema: &swagger.Schema{
-							SchemaProps: swagger.SchemaProps{
-								Type: []string{"string"},
-							},
-						},
-					},
-				},
-			}, nil
 		}
-		ref, err := swagger.NewRef("#/definitions/" + itemRef)
+		addObfuscatedName(&def, sig)
+		spec.Definitions[shortName] = def
+		return refSchema(shortName)
+	}
+
+	fields := class.instanceFields()
+	order := map[string]int{}
+	if class.Kotlin != nil {
+		// dex sorts fields by name, kotlin metadata knows the declaration order
+		for i, name := range class.Kotlin.propertyOrder() {
+			order[name] = i
+		}
+		sort.SliceStable(fields, func(i, j int) bool {
+			oi, iok := order[fields[i].Name]
+			oj, jok := order[fields[j].Name]
+			return iok && (!jok || oi < oj)
+		})
+	}
+	reader := serializerFor(class)
+	log.Printf("  building schema with %d fields using %s => %s", len(fields), reader.Name(), shortName)
+	schemaProps := map[string]swagger.Schema{}
+	var required []string
+	for _, field := range fields {
+		info := reader.ReadField(class, field)
+		if info.Ignored {
+			log.Printf("  field %s ignored by %s", field.Name, reader.Name())
+			continue
+		}
+		b, err := interpretFieldType(field, bindings, spec)
 		if err != nil {
-			return nil, fmt.Errorf("error building ref: %w", err)
+			return nil, fmt.Errorf("interpretTypeAndBuildDefinition: %w", err)
 		}
-		return &swagger.Schema{
-			SchemaProps: swagger.SchemaProps{
-				Type: []string{"array"},
-				Items: &swagger.SchemaOrArray{
-					Schema: &swagger.Schema{
-						SchemaProps: swagger.SchemaProps{
-							Ref: ref,
-						},
-					},
-				},
-			},
-		}, nil
-	case "map":
-		return &swagger.Schema{
-			SchemaProps: swagger.SchemaProps{
-				Type: []string{"object"},
-				AdditionalProperties: &swagger.SchemaOrBool{
-					Allows: true,
-					Schema: &swagger.Schema{
-						SchemaProps: swagger.SchemaProps{
-							Type: []string{itemRef},
-						},
-					},
-				},
-			},
-		}, nil
-	case "object":
-		if itemRef == "" {
-			return &swagger.Schema{
-				SchemaProps: swagger.SchemaProps{
-					Type: []string{"object"},
-				},
-			}, nil
+		if b == nil {
+			// a Void field carries nothing
+			continue
+		}
+		extensions := swagger.Extensions{}
+		if field.OriginalName != "" && info.Name == field.Name {
+			// not renamed by the serializer => named after the original field
+			info.Name = field.OriginalName
+			extensions.Add("x-obfuscated-name", field.Name)
+		}
+		if _, dup := schemaProps[info.Name]; dup {
+			addDiagnostic("%s: field %s is serialized as %q which is already taken, skipped", shortName, field.Name, info.Name)
+			continue
 		}
-		ref, err := swagger.NewRef("#/definitions/" + itemRef)
+		if len(info.Alternates) > 0 {
+			extensions.Add("x-alternate-names", info.Alternates)
+		}
+		if i, ok := order[field.Name]; ok {
+			extensions.Add("x-order", float64(i))
+		}
+		if class.Kotlin != nil {
+			if prop, ok := class.Kotlin.property(field.Name); ok {
+				if prop.Nullable {
+					extensions.Add("x-nullable", true)
+				} else if !info.Optional {
+					info.Required = true
+				}
+			}
+		}
+		if info.Required {
+			required = append(required, info.Name)
+		}
+		schemaProps[info.Name] = withExtensions(*b, extensions)
+	}
+	def := swagger.Schema{
+		SchemaProps: swagger.SchemaProps{
+			Type:       []string{"object"},
+			Properties: schemaProps,
+			Required:   required,
+		},
+	}
+
+	// inherited properties live in the parent's own definition => allOf [parent, own props]
+	if parent := class.appSuperclass(); parent != "" {
+		log.Printf("  %s extends %s", shortName, parent)
+		parentType := &jvmType{Kind: jvmClass, Name: strings.TrimSuffix(strings.TrimPrefix(parent, "L"), ";")}
+		if class.Signature != nil && class.Signature.Super.descriptor() == parent {
+			// e.g. DeviceEnvelope extends Envelope<Device> => allOf Envelope_Device
+			parentType = class.Signature.Super.substitute(bindings)
+		}
+		parentSchema, err := schemaForType(parentType, spec)
 		if err != nil {
-			return nil, fmt.Errorf("error building ref: %w", err)
+			return nil, fmt.Errorf("interpretTypeAndBuildDefinition: %w", err)
 		}
-		return &swagger.Schema{
+		def = swagger.Schema{
 			SchemaProps: swagger.SchemaProps{
-				Ref: ref,
+				AllOf: []swagger.Schema{*parentSchema, def},
 			},
-		}, nil
+		}
+	}
+	if interfaces := class.appInterfaces(); len(interfaces) > 0 {
+		var names []string
+		for _, i := range interfaces {
+			names = append(names, typeShortName(i))
+		}
+		def.AddExtension("x-implements", names)
+	}
+	addObfuscatedName(&def, sig)
+	spec.Definitions[shortName] = def
+	return refSchema(shortName)
+}
+
+// interpretFieldType builds the schema of a field, substituting the type arguments its class was instantiated with
+func interpretFieldType(field SmaliField, bindings map[string]*jvmType, spec *swagger.Swagger) (*swagger.Schema, error) {
+	t, err := parseTypeSignature(field.signature())
+	if err != nil {
+		addDiagnostic("could not parse the signature of field %s: %v, using string", field.Name, err)
+		return primitiveSchema("string"), nil
+	}
+	return schemaForType(t.substitute(bindings), spec)
+}
+
+// Type each definition name was claimed by, e.g. "Device" => "Lcom/example/models/Device;",
+// to tell same named types apart. Only names the spec has count, see claimDefinitionName.
+var definitionOwners = map[string]string{}
+
+// claimDefinitionName returns the definition name of a type, e.g. "Lcom/example/models/Device;" or
+// "Lcom/example/Envelope<Lcom/example/models/Device;>;", and whether the spec already has its definition.
+// A name taken by another type, e.g. an inner class Envelope$Device next to Envelope<Device> or a
+// Device of another package, gets a numbered suffix: Device_2.
+func claimDefinitionName(spec *swagger.Swagger, name, typ string) (string, bool) {
+	for n := 1; ; n++ {
+		candidate := name
+		if n > 1 {
+			candidate = fmt.Sprintf("%s_%d", name, n)
+		}
+		if _, found := spec.Definitions[candidate]; !found {
+			if n > 1 {
+				addDiagnostic("definition %s is taken by %s, %s is named %s", name, definitionOwners[name], typ, candidate)
+			}
+			definitionOwners[candidate] = typ
+			return candidate, false
+		}
+		if owner, ok := definitionOwners[candidate]; !ok || owner == typ {
+			return candidate, true
+		}
+	}
+}
+
+// definitionName names the definition of a generic instantiation, e.g. Envelope<List<Device>> => Envelope_List_Device
+func definitionName(t *jvmType) string {
+	switch t.Kind {
+	case jvmClass:
+		name := typeShortName(t.descriptor())
+		for _, a := range t.Args {
+			name += "_" + definitionName(a)
+		}
+		return name
+	case jvmArray:
+		return definitionName(t.Elem) + "Array"
+	case jvmWildcard:
+		if t.Elem == nil {
+			return "Object"
+		}
+		return definitionName(t.Elem)
 	default:
-		log.Printf("  fallback => string property")
-		return &swagger.Schema{
-			SchemaProps: swagger.SchemaProps{
-				Type: []string{"string"},
-			},
-		}, nil
+		return t.Name
+	}
+}
+
+// withExtensions adds extensions to a property schema. Swagger ignores the siblings of a $ref,
+// so a reference is wrapped in an allOf carrying them.
+func withExtensions(schema swagger.Schema, extensions swagger.Extensions) swagger.Schema {
+	if len(extensions) == 0 {
+		return schema
+	}
+	if schema.Ref.String() != "" {
+		schema = swagger.Schema{SchemaProps: swagger.SchemaProps{AllOf: []swagger.Schema{schema}}}
+	}
+	for k, v := range extensions {
+		schema.AddExtension(k, v)
+	}
+	return schema
+}
+
+// addObfuscatedName keeps the name of an obfuscated class on its definition, e.g. x-obfuscated-name: a.b
+func addObfuscatedName(def *swagger.Schema, sig string) {
+	if original := originalClassName(sig); original != sig {
+		def.AddExtension("x-obfuscated-name", strings.ReplaceAll(binaryClassName(sig), "/", "."))
+	}
+}
+
+func builtinSchema(b builtinType) *swagger.Schema {
+	return &swagger.Schema{
+		SchemaProps: swagger.SchemaProps{
+			Type:   []string{b.typ},
+			Format: b.format,
+		},
+	}
+}
+
+func primitiveSchema(kind string) *swagger.Schema {
+	return &swagger.Schema{
+		SchemaProps: swagger.SchemaProps{
+			Type: []string{kind},
+		},
+	}
+}
+
+func emptyObjectDefinition() swagger.Schema {
+	return swagger.Schema{
+		SchemaProps: swagger.SchemaProps{
+			Type:       []string{"object"},
+			Properties: map[string]swagger.Schema{},
+		},
+	}
+}
+
+func refSchema(name string) (*swagger.Schema, error) {
+	ref, err := swagger.NewRef("#/definitions/" + name)
+	if err != nil {
+		return nil, fmt.Errorf("error building ref: %w", err)
+	}
+	return &swagger.Schema{
+		SchemaProps: swagger.SchemaProps{
+			Ref: ref,
+		},
+	}, nil
+}
+
+// buildResponse wraps the schema of a return type, nil meaning no content
+func buildResponse(schema *swagger.Schema) *swagger.Response {
+	return &swagger.Response{
+		ResponseProps: swagger.ResponseProps{
+			Description: "OK",
+			Schema:      schema,
+		},
 	}
 }
 
-// typeShortName: from Luk/co/goptions/.../SomeClass; => "SomeClass"
+// typeShortName: from Luk/co/goptions/.../SomeClass; => "SomeClass",
+// obfuscated classes are named after their original class
 func typeShortName(sig string) string {
-	tmp := strings.TrimPrefix(sig, "L")
+	tmp := strings.TrimPrefix(originalClassName(sig), "L")
 	tmp = strings.TrimSuffix(tmp, ";")
 	parts := strings.Split(tmp, "/")
 	p := parts[len(parts)-1]
@@ -4,6 +4,9 @@ import (
 	_ "embed"
 	"encoding/json"
 	"fmt"
+	"maps"
+	"slices"
+	"strings"
 	"testing"
 
 	swagger "github.com/go-openapi/spec"
@@ -12,6 +15,30 @@ import (
 //go:embed testdata/FeaturesApi.smali
 var featuresApiSmali string
 
+// isolateGlobals snapshots the state the scans and loaders keep in package globals and
+// restores it when the test ends, so tests can register classes and scan freely
+func isolateGlobals(t *testing.T) {
+	t.Helper()
+	classes, diags := maps.Clone(classToFilePath), slices.Clone(diagnostics)
+	sites, bindings, providers := slices.Clone(baseURLSites), maps.Clone(interfaceBindings), maps.Clone(retrofitProviders)
+	params, interceptors, secrets := maps.Clone(interceptorParams), maps.Clone(interfaceInterceptors), showSecrets
+	flows, issuers := slices.Clone(oauthFlows), slices.Clone(openIDIssuers)
+	resources, resourceIDs := maps.Clone(stringResources), maps.Clone(stringResourceIDs)
+	mappings, proguard := slices.Clone(typeMappings), proguardClasses
+	wrappers, readers := maps.Clone(wrapperRegistry), slices.Clone(serializerReaders)
+	info, title, version, owners := app, titleOverride, versionOverride, maps.Clone(definitionOwners)
+	t.Cleanup(func() {
+		classToFilePath, diagnostics = classes, diags
+		baseURLSites, interfaceBindings, retrofitProviders = sites, bindings, providers
+		interceptorParams, interfaceInterceptors, showSecrets = params, interceptors, secrets
+		oauthFlows, openIDIssuers = flows, issuers
+		stringResources, stringResourceIDs = resources, resourceIDs
+		typeMappings, proguardClasses = mappings, proguard
+		wrapperRegistry, serializerReaders = wrappers, readers
+		app, titleOverride, versionOverride, definitionOwners = info, title, version, owners
+	})
+}
+
 func TestParseSmaliMethods(t *testing.T) {
 	// Parse methods from Smali file
 	methods := parseSmaliMethods(featuresApiSmali)
@@ -64,3 +91,782 @@ func TestEncode(t *testing.T) {
 	}
 	fmt.Println(string(b))
 }
+
+const verbsApiSmali = `.class public interface abstract Lcom/example/VerbsApi;
+.super Ljava/lang/Object;
+
+# virtual methods
+.method public abstract patchThing(Lcom/example/Thing;)Lretrofit2/Call;
+    .annotation runtime Lretrofit2/http/PATCH;
+        value = "things/1"
+    .end annotation
+.end method
+
+.method public abstract headThing()Lretrofit2/Call;
+    .annotation runtime Lretrofit2/http/HEAD;
+        value = "things/1"
+    .end annotation
+.end method
+
+.method public abstract deleteWithBody(Lcom/example/Thing;)Lretrofit2/Call;
+    .param p1    # Lcom/example/Thing;
+        .annotation runtime Lretrofit2/http/Body;
+        .end annotation
+    .end param
+    .annotation runtime Lretrofit2/http/HTTP;
+        hasBody = true
+        method = "DELETE"
+        path = "things/{id}"
+    .end annotation
+.end method
+
+.method public abstract reportThing()Lretrofit2/Call;
+    .annotation runtime Lretrofit2/http/HTTP;
+        method = "REPORT"
+        path = "things"
+    .end annotation
+.end method
+
+.method public abstract noVerb()Lretrofit2/Call;
+    .annotation runtime Lretrofit2/http/HTTP;
+        path = "things/none"
+    .end annotation
+.end method
+`
+
+func TestHTTPVerbs(t *testing.T) {
+	isolateGlobals(t)
+	diagnostics = nil
+	apis, err := ExtractAPIEndpoints(verbsApiSmali)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(apis) != 4 {
+		t.Fatalf("Expected 4 endpoints, got %d", len(apis))
+	}
+	if apis[2].Method != "DELETE" || apis[2].Path != "things/{id}" || !apis[2].HasBody {
+		t.Errorf("Unexpected @HTTP endpoint: %+v", apis[2])
+	}
+
+	spec, err := GenerateSwaggerSpec(apis)
+	if err != nil {
+		t.Fatal(err)
+	}
+	item := spec.Paths.Paths["/things/1"]
+	if item.Patch == nil || item.Head == nil || item.Post != nil {
+		t.Errorf("Expected PATCH and HEAD operations only, got %+v", item)
+	}
+	if del := spec.Paths.Paths["/things/{id}"].Delete; del == nil || !hasBody(del.Parameters) {
+		t.Errorf("Expected DELETE with a body parameter")
+	}
+	if _, ok := spec.Paths.Paths["/things"]; ok {
+		t.Errorf("Expected custom verb to stay out of paths")
+	}
+	custom, _ := spec.Extensions["x-custom-method-operations"].([]map[string]interface{})
+	if len(custom) != 1 || custom[0]["method"] != "REPORT" || custom[0]["path"] != "/things" {
+		t.Errorf("Expected the custom verb operation in x-custom-method-operations, got %v", custom)
+	}
+	if got := Diagnostics(); len(got) != 2 || !strings.Contains(got[0], "noVerb") || !strings.Contains(got[1], `"REPORT"`) {
+		t.Errorf("Expected diagnostics for the @HTTP without a method and the custom verb, got %v", got)
+	}
+}
+
+const headersApiSmali = `.class public interface abstract Lcom/example/HeadersApi;
+.super Ljava/lang/Object;
+
+# virtual methods
+.method public abstract getThing(Ljava/lang/String;Ljava/util/Map;)Lretrofit2/Call;
+    .param p1    # Ljava/lang/String;
+        .annotation runtime Lretrofit2/http/Header;
+            value = "X-Tenant-Id"
+        .end annotation
+    .end param
+    .param p2    # Ljava/util/Map;
+        .annotation runtime Lretrofit2/http/HeaderMap;
+        .end annotation
+    .end param
+    .annotation runtime Lretrofit2/http/GET;
+        value = "things"
+    .end annotation
+    .annotation runtime Lretrofit2/http/Headers;
+        value = {
+            "Accept: application/json",
+            "X-Client: android",
+            "X-Tenant-Id: default",
+            "X-Broken"
+        }
+    .end annotation
+.end method
+`
+
+func TestHeaderParams(t *testing.T) {
+	isolateGlobals(t)
+	diagnostics = nil
+	apis, err := ExtractAPIEndpoints(headersApiSmali)
+	if err != nil {
+		t.Fatal(err)
+	}
+	spec, err := GenerateSwaggerSpec(apis)
+	if err != nil {
+		t.Fatal(err)
+	}
+	op := spec.Paths.Paths["/things"].Get
+	if op == nil {
+		t.Fatal("Expected GET /things")
+	}
+
+	headers := map[string]swagger.Parameter{}
+	for _, p := range op.Parameters {
+		if p.In != "header" {
+			t.Errorf("Unexpected non header param %s in %s", p.Name, p.In)
+		}
+		if _, ok := headers[p.Name]; ok {
+			t.Errorf("Unexpected duplicate header param %s", p.Name)
+		}
+		headers[p.Name] = p
+	}
+	if tenant, ok := headers["X-Tenant-Id"]; !ok || tenant.Required {
+		t.Errorf("Expected @Header param X-Tenant-Id, got %+v", tenant)
+	}
+	if accept, ok := headers["Accept"]; !ok || !accept.Required || accept.Default != "application/json" {
+		t.Errorf("Expected fixed Accept header, got %+v", accept)
+	}
+	if _, ok := op.Extensions["x-header-map"]; !ok {
+		t.Errorf("Expected x-header-map extension")
+	}
+	if _, ok := headers["X-Broken"]; ok {
+		t.Errorf("Expected the malformed @Headers entry to be skipped")
+	}
+	if got := Diagnostics(); len(got) != 1 || !strings.Contains(got[0], `"X-Broken"`) {
+		t.Errorf("Expected a diagnostic for the malformed @Headers entry, got %v", got)
+	}
+}
+
+const formApiSmali = `.class public interface abstract Lcom/example/FormApi;
+.super Ljava/lang/Object;
+
+# virtual methods
+.method public abstract login(Ljava/lang/String;Ljava/lang/String;Ljava/util/Map;)Lretrofit2/Call;
+    .param p1    # Ljava/lang/String;
+        .annotation runtime Lretrofit2/http/Field;
+            value = "username"
+        .end annotation
+    .end param
+    .param p2    # Ljava/lang/String;
+        .annotation runtime Lretrofit2/http/Field;
+            encoded = true
+            value = "password"
+        .end annotation
+    .end param
+    .param p3    # Ljava/util/Map;
+        .annotation runtime Lretrofit2/http/FieldMap;
+        .end annotation
+    .end param
+    .annotation runtime Lretrofit2/http/FormUrlEncoded;
+    .end annotation
+    .annotation runtime Lretrofit2/http/POST;
+        value = "login"
+    .end annotation
+.end method
+
+.method public abstract upload(Lokhttp3/RequestBody;Lokhttp3/MultipartBody$Part;)Lretrofit2/Call;
+    .param p1    # Lokhttp3/RequestBody;
+        .annotation runtime Lretrofit2/http/Part;
+            value = "description"
+        .end annotation
+    .end param
+    .param p2    # Lokhttp3/MultipartBody$Part;
+        .annotation runtime Lretrofit2/http/Part;
+        .end annotation
+    .end param
+    .annotation runtime Lretrofit2/http/Multipart;
+    .end annotation
+    .annotation runtime Lretrofit2/http/POST;
+        value = "upload"
+    .end annotation
+.end method
+`
+
+func TestFormParams(t *testing.T) {
+	isolateGlobals(t)
+	diagnostics = nil
+	apis, err := ExtractAPIEndpoints(formApiSmali)
+	if err != nil {
+		t.Fatal(err)
+	}
+	spec, err := GenerateSwaggerSpec(apis)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	login := spec.Paths.Paths["/login"].Post
+	if login == nil || len(login.Consumes) != 1 || login.Consumes[0] != "application/x-www-form-urlencoded" {
+		t.Fatalf("Expected form encoded POST /login, got %+v", login)
+	}
+	if len(login.Parameters) != 2 || login.Parameters[0].In != "formData" || login.Parameters[1].Name != "password" {
+		t.Errorf("Unexpected login params: %+v", login.Parameters)
+	}
+	if _, ok := login.Extensions["x-field-map"]; !ok {
+		t.Errorf("Expected x-field-map extension")
+	}
+
+	upload := spec.Paths.Paths["/upload"].Post
+	if upload == nil || len(upload.Consumes) != 1 || upload.Consumes[0] != "multipart/form-data" {
+		t.Fatalf("Expected multipart POST /upload, got %+v", upload)
+	}
+	for _, p := range upload.Parameters {
+		if p.In != "formData" || p.Type != "file" {
+			t.Errorf("Expected file formData param, got %s in %s of type %s", p.Name, p.In, p.Type)
+		}
+	}
+	if len(upload.Parameters) != 2 || upload.Parameters[1].Name != "part1" || upload.Parameters[1].Description == "" {
+		t.Errorf("Expected the MultipartBody.Part to get a stable name and a description, got %+v", upload.Parameters)
+	}
+	found := false
+	for _, d := range Diagnostics() {
+		if strings.Contains(d, "names itself at runtime") {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("Expected the runtime part name to be reported, got %v", Diagnostics())
+	}
+}
+
+const explicitApiSmali = `.class public interface abstract Lcom/example/ExplicitApi;
+.super Ljava/lang/Object;
+
+# virtual methods
+.method public abstract patchThing(Lcom/example/Thing;Ljava/util/Map;Ljava/lang/String;)Lretrofit2/Call;
+    .param p1    # Lcom/example/Thing;
+        .annotation runtime Lretrofit2/http/Body;
+        .end annotation
+    .end param
+    .param p2    # Ljava/util/Map;
+        .annotation runtime Lretrofit2/http/QueryMap;
+        .end annotation
+    .end param
+    .param p3    # Ljava/lang/String;
+        .annotation runtime Lretrofit2/http/QueryName;
+        .end annotation
+    .end param
+    .annotation runtime Lretrofit2/http/PATCH;
+        value = "things/1"
+    .end annotation
+.end method
+
+.method public abstract download(Ljava/lang/String;)Lretrofit2/Call;
+    .param p1    # Ljava/lang/String;
+        .annotation runtime Lretrofit2/http/Url;
+        .end annotation
+    .end param
+    .annotation runtime Lretrofit2/http/GET;
+    .end annotation
+.end method
+
+.method public abstract search(Lcom/example/Thing;)Lretrofit2/Call;
+    .param p1    # Lcom/example/Thing;
+        .annotation runtime Lretrofit2/http/Body;
+        .end annotation
+    .end param
+    .annotation runtime Lretrofit2/http/GET;
+        value = "search"
+    .end annotation
+.end method
+
+.method public abstract propfind(Ljava/lang/String;)Lretrofit2/Call;
+    .param p1    # Ljava/lang/String;
+        .annotation runtime Lretrofit2/http/Url;
+        .end annotation
+    .end param
+    .annotation runtime Lretrofit2/http/HTTP;
+        method = "PROPFIND"
+    .end annotation
+.end method
+`
+
+func TestExplicitParamAnnotations(t *testing.T) {
+	isolateGlobals(t)
+	diagnostics = nil
+	apis, err := ExtractAPIEndpoints(explicitApiSmali)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(apis) != 4 || !apis[1].DynamicURL || !apis[3].DynamicURL {
+		t.Fatalf("Expected 4 endpoints with the second and last one dynamic, got %d", len(apis))
+	}
+	spec, err := GenerateSwaggerSpec(apis)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	patch := spec.Paths.Paths["/things/1"].Patch
+	if patch == nil {
+		t.Fatal("Expected PATCH /things/1")
+	}
+	if !hasBody(patch.Parameters) {
+		t.Errorf("Expected @Body on PATCH to become a body param")
+	}
+	if _, ok := patch.Extensions["x-query-map"]; !ok {
+		t.Errorf("Expected x-query-map extension")
+	}
+	var flag *swagger.Parameter
+	for i, p := range patch.Parameters {
+		if p.In == "query" {
+			flag = &patch.Parameters[i]
+		}
+	}
+	if flag == nil || !flag.AllowEmptyValue || flag.Name != "queryName" {
+		t.Errorf("Expected a valueless @QueryName param named queryName, got %+v", flag)
+	}
+
+	if search := spec.Paths.Paths["/search"].Get; search == nil || hasBody(search.Parameters) {
+		t.Errorf("Expected @Body on GET to be dropped, got %+v", search)
+	}
+	if len(spec.Paths.Paths) != 2 {
+		t.Errorf("Expected the @Url endpoints to stay out of paths, got %d paths", len(spec.Paths.Paths))
+	}
+	if _, ok := spec.Extensions["x-dynamic-url-operations"]; !ok {
+		t.Errorf("Expected x-dynamic-url-operations extension")
+	}
+	for _, want := range []string{"search has a @Body param on GET", `propfind uses unsupported HTTP verb "PROPFIND"`} {
+		found := false
+		for _, d := range Diagnostics() {
+			if strings.Contains(d, want) {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("Expected a diagnostic containing %q, got %v", want, Diagnostics())
+		}
+	}
+}
+
+func TestSerializedNames(t *testing.T) {
+	isolateGlobals(t)
+	classToFilePath["Lcom/example/models/Device;"] = "testdata/Device.smali"
+	spec := &swagger.Swagger{SwaggerProps: swagger.SwaggerProps{Definitions: map[string]swagger.Schema{}}}
+
+	schema, err := interpretTypeAndBuildDefinition("Lcom/example/models/Device;", spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if schema.Ref.String() != "#/definitions/Device" {
+		t.Fatalf("Expected a Device ref, got %+v", schema)
+	}
+
+	props := spec.Definitions["Device"].Properties
+	if len(props) != 3 {
+		t.Errorf("Expected 3 properties, got %d", len(props))
+	}
+	for _, name := range []string{"device_id", "battery_level", "label"} {
+		if _, ok := props[name]; !ok {
+			t.Errorf("Expected property %s", name)
+		}
+	}
+	alternates, _ := props["device_id"].Extensions["x-alternate-names"].([]string)
+	if len(alternates) != 2 || alternates[0] != "deviceId" {
+		t.Errorf("Expected alternate names, got %v", alternates)
+	}
+}
+
+func TestInheritance(t *testing.T) {
+	isolateGlobals(t)
+	classToFilePath["Lcom/example/models/Device;"] = "testdata/Device.smali"
+	classToFilePath["Lcom/example/models/BaseResponse;"] = "testdata/BaseResponse.smali"
+	classToFilePath["Lcom/example/models/DeviceResponse;"] = "testdata/DeviceResponse.smali"
+	classToFilePath["Lcom/example/models/Identifiable;"] = "testdata/Identifiable.smali"
+	spec := &swagger.Swagger{SwaggerProps: swagger.SwaggerProps{Definitions: map[string]swagger.Schema{}}}
+
+	if _, err := interpretTypeAndBuildDefinition("Lcom/example/models/DeviceResponse;", spec); err != nil {
+		t.Fatal(err)
+	}
+
+	def := spec.Definitions["DeviceResponse"]
+	if len(def.AllOf) != 2 {
+		t.Fatalf("Expected allOf with parent and own properties, got %+v", def)
+	}
+	if def.AllOf[0].Ref.String() != "#/definitions/BaseResponse" {
+		t.Errorf("Expected parent ref, got %s", def.AllOf[0].Ref.String())
+	}
+	if _, ok := def.AllOf[1].Properties["device"]; !ok || len(def.AllOf[1].Properties) != 1 {
+		t.Errorf("Expected only own properties, got %v", def.AllOf[1].Properties)
+	}
+	if parent := spec.Definitions["BaseResponse"]; len(parent.Properties) != 2 {
+		t.Errorf("Expected parent definition with 2 properties, got %v", parent.Properties)
+	}
+	if implements, _ := def.Extensions["x-implements"].([]string); len(implements) != 1 || implements[0] != "Identifiable" {
+		t.Errorf("Expected x-implements Identifiable, got %v", def.Extensions["x-implements"])
+	}
+}
+
+func TestEnum(t *testing.T) {
+	isolateGlobals(t)
+	classToFilePath["Lcom/example/models/DeviceStatus;"] = "testdata/DeviceStatus.smali"
+	spec := &swagger.Swagger{SwaggerProps: swagger.SwaggerProps{Definitions: map[string]swagger.Schema{}}}
+
+	schema, err := interpretTypeAndBuildDefinition("Lcom/example/models/DeviceStatus;", spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if schema.Ref.String() != "#/definitions/DeviceStatus" {
+		t.Fatalf("Expected a DeviceStatus ref, got %+v", schema)
+	}
+
+	def := spec.Definitions["DeviceStatus"]
+	if !def.Type.Contains("string") {
+		t.Errorf("Expected string enum, got %v", def.Type)
+	}
+	if fmt.Sprint(def.Enum) != "[offline online UNKNOWN]" {
+		t.Errorf("Unexpected enum values %v", def.Enum)
+	}
+}
+
+func TestFieldGenericSignatures(t *testing.T) {
+	isolateGlobals(t)
+	classToFilePath["Lcom/example/models/Device;"] = "testdata/Device.smali"
+	classToFilePath["Lcom/example/models/Fleet;"] = "testdata/Fleet.smali"
+	spec := &swagger.Swagger{SwaggerProps: swagger.SwaggerProps{Definitions: map[string]swagger.Schema{}}}
+
+	if _, err := interpretTypeAndBuildDefinition("Lcom/example/models/Fleet;", spec); err != nil {
+		t.Fatal(err)
+	}
+
+	props := spec.Definitions["Fleet"].Properties
+	devices := props["devices"]
+	if !devices.Type.Contains("array") || devices.Items.Schema.Ref.String() != "#/definitions/Device" {
+		t.Errorf("Expected devices to be an array of Device, got %+v", devices)
+	}
+	byID := props["byId"]
+	if !byID.Type.Contains("object") || byID.AdditionalProperties.Schema.Ref.String() != "#/definitions/Device" {
+		t.Errorf("Expected byId to be a map of Device, got %+v", byID)
+	}
+	labels := props["labels"]
+	if !labels.AdditionalProperties.Schema.Type.Contains("string") {
+		t.Errorf("Expected labels to be a map of string, got %+v", labels)
+	}
+}
+
+func TestGenericModels(t *testing.T) {
+	isolateGlobals(t)
+	classToFilePath["Lcom/example/models/Device;"] = "testdata/Device.smali"
+	classToFilePath["Lcom/example/models/Envelope;"] = "testdata/Envelope.smali"
+	spec := &swagger.Swagger{SwaggerProps: swagger.SwaggerProps{Definitions: map[string]swagger.Schema{}}}
+
+	for i := 0; i < 2; i++ {
+		schema, err := interpretTypeAndBuildDefinition("Lcom/example/models/Envelope<Lcom/example/models/Device;>;", spec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if schema.Ref.String() != "#/definitions/Envelope_Device" {
+			t.Fatalf("Expected the Envelope_Device instantiation, got %+v", schema)
+		}
+	}
+
+	props := spec.Definitions["Envelope_Device"].Properties
+	if data := props["data"]; data.Ref.String() != "#/definitions/Device" {
+		t.Errorf("Expected data to be a Device, got %+v", data)
+	}
+	if history := props["history"]; !history.Type.Contains("array") || history.Items.Schema.Ref.String() != "#/definitions/Device" {
+		t.Errorf("Expected history to be an array of Device, got %+v", history)
+	}
+	if !props["code"].Type.Contains("integer") {
+		t.Errorf("Expected code to stay an integer, got %+v", props["code"])
+	}
+
+	// a raw Envelope has nothing to substitute
+	if _, err := interpretTypeAndBuildDefinition("Lcom/example/models/Envelope;", spec); err != nil {
+		t.Fatal(err)
+	}
+	if data := spec.Definitions["Envelope"].Properties["data"]; !data.Type.Contains("object") {
+		t.Errorf("Expected raw data to be a free-form object, got %+v", data)
+	}
+}
+
+func TestDefinitionNameCollisions(t *testing.T) {
+	isolateGlobals(t)
+	diagnostics = nil
+	classToFilePath["Lcom/example/models/Device;"] = "testdata/Device.smali"
+	classToFilePath["Lcom/example/models/Envelope;"] = "testdata/Envelope.smali"
+	spec := &swagger.Swagger{SwaggerProps: swagger.SwaggerProps{Definitions: map[string]swagger.Schema{}}}
+
+	for sig, want := range map[string]string{
+		"Lcom/example/models/Envelope<Lcom/example/models/Device;>;": "Envelope_Device",
+		// rendered the same as the instantiation
+		"Lcom/example/models/Envelope$Device;": "Envelope_Device_2",
+		// same short name, another package
+		"Lcom/example/legacy/Device;": "Device_2",
+	} {
+		// the order decides who gets the plain name, build the instantiation first
+		if _, err := interpretTypeAndBuildDefinition("Lcom/example/models/Envelope<Lcom/example/models/Device;>;", spec); err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < 2; i++ {
+			schema, err := interpretTypeAndBuildDefinition(sig, spec)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if schema.Ref.String() != "#/definitions/"+want {
+				t.Errorf("%s: expected a ref to %s, got %+v", sig, want, schema)
+			}
+		}
+	}
+	if len(Diagnostics()) != 2 {
+		t.Errorf("Expected each collision to be reported once, got %v", Diagnostics())
+	}
+}
+
+const arraysApiSmali = `.class public interface abstract Lcom/example/ArraysApi;
+.super Ljava/lang/Object;
+
+# virtual methods
+.method public abstract search([Ljava/lang/String;[J)Lretrofit2/Call;
+    .param p1    # [Ljava/lang/String;
+        .annotation runtime Lretrofit2/http/Query;
+            value = "tag"
+        .end annotation
+    .end param
+    .param p2    # [J
+        .annotation runtime Lretrofit2/http/Header;
+            value = "X-Ids"
+        .end annotation
+    .end param
+    .annotation runtime Lretrofit2/http/GET;
+        value = "search"
+    .end annotation
+.end method
+
+.method public abstract byIds(Ljava/util/List;Ljava/util/Set;)Lretrofit2/Call;
+    .annotation system Ldalvik/annotation/Signature;
+        value = {
+            "(",
+            "Ljava/util/List<",
+            "Ljava/lang/Long;",
+            ">;",
+            "Ljava/util/Set<",
+            "+",
+            "Ljava/lang/String;",
+            ">;)",
+            "Lretrofit2/Call<",
+            "Ljava/lang/Void;",
+            ">;"
+        }
+    .end annotation
+    .param p1    # Ljava/util/List;
+        .annotation runtime Lretrofit2/http/Query;
+            value = "ids"
+        .end annotation
+    .end param
+    .param p2    # Ljava/util/Set;
+        .annotation runtime Lretrofit2/http/Header;
+            value = "X-Tags"
+        .end annotation
+    .end param
+    .annotation runtime Lretrofit2/http/GET;
+        value = "devices"
+    .end annotation
+.end method
+`
+
+func TestArrays(t *testing.T) {
+	isolateGlobals(t)
+	classToFilePath["Lcom/example/models/Device;"] = "testdata/Device.smali"
+	spec := &swagger.Swagger{SwaggerProps: swagger.SwaggerProps{Definitions: map[string]swagger.Schema{}}}
+
+	schema, err := interpretTypeAndBuildDefinition("[[Lcom/example/models/Device;", spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !schema.Type.Contains("array") || !schema.Items.Schema.Type.Contains("array") ||
+		schema.Items.Schema.Items.Schema.Ref.String() != "#/definitions/Device" {
+		t.Errorf("Expected an array of Device arrays, got %+v", schema)
+	}
+	schema, _ = interpretTypeAndBuildDefinition("[I", spec)
+	if !schema.Type.Contains("array") || !schema.Items.Schema.Type.Contains("integer") {
+		t.Errorf("Expected an array of integers, got %+v", schema)
+	}
+	schema, _ = interpretTypeAndBuildDefinition("[B", spec)
+	if !schema.Type.Contains("string") || schema.Format != "byte" {
+		t.Errorf("Expected base64 string for byte[], got %+v", schema)
+	}
+
+	apis, err := ExtractAPIEndpoints(arraysApiSmali)
+	if err != nil {
+		t.Fatal(err)
+	}
+	spec, err = GenerateSwaggerSpec(apis)
+	if err != nil {
+		t.Fatal(err)
+	}
+	params := spec.Paths.Paths["/search"].Get.Parameters
+	if len(params) != 2 {
+		t.Fatalf("Expected 2 params, got %+v", params)
+	}
+	if tag := params[0]; tag.Type != "array" || tag.CollectionFormat != "multi" || tag.Items.Type != "string" {
+		t.Errorf("Expected a repeated string array query param, got %+v", tag)
+	}
+	if ids := params[1]; ids.Type != "array" || ids.CollectionFormat != "csv" || ids.Items.Type != "integer" {
+		t.Errorf("Expected a csv integer array header, got %+v", ids)
+	}
+
+	params = spec.Paths.Paths["/devices"].Get.Parameters
+	if len(params) != 2 {
+		t.Fatalf("Expected 2 params, got %+v", params)
+	}
+	if ids := params[0]; ids.Type != "array" || ids.CollectionFormat != "multi" || ids.Items.Type != "integer" || ids.Items.Format != "int64" {
+		t.Errorf("Expected a repeated int64 query param for List<Long>, got %+v", ids)
+	}
+	if tags := params[1]; tags.Type != "array" || tags.CollectionFormat != "csv" || tags.Items.Type != "string" {
+		t.Errorf("Expected a csv string array header for Set<? extends String>, got %+v", tags)
+	}
+	if s := simpleSchema("Ljava/util/List;", "multi"); s.Type != "array" || s.Items.Type != "string" {
+		t.Errorf("Expected a raw List to be an array of strings, got %+v", s)
+	}
+}
+
+func TestBuiltinTypes(t *testing.T) {
+	spec := &swagger.Swagger{SwaggerProps: swagger.SwaggerProps{Definitions: map[string]swagger.Schema{}}}
+
+	for sig, want := range map[string]string{
+		"J":                                  "integer int64",
+		"I":                                  "integer int32",
+		"D":                                  "number double",
+		"C":                                  "string ",
+		"Ljava/util/Date;":                   "string date-time",
+		"Ljava/time/OffsetDateTime;":         "string date-time",
+		"Ljava/time/LocalDate;":              "string date",
+		"Ljava/util/UUID;":                   "string uuid",
+		"Ljava/math/BigDecimal;":             "number decimal",
+		"Lkotlin/Any;":                       "object ",
+		"Ljava/util/List<Ljava/lang/Long;>;": "array ",
+	} {
+		schema, err := interpretTypeAndBuildDefinition(sig, spec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := schema.Type[0] + " " + schema.Format; got != want {
+			t.Errorf("%s: expected %q, got %q", sig, want, got)
+		}
+	}
+	if schema, _ := interpretTypeAndBuildDefinition("()Lretrofit2/Call<Lkotlin/Unit;>;", spec); schema != nil {
+		t.Errorf("Expected Unit to have no content, got %+v", schema)
+	}
+	if len(spec.Definitions) != 0 {
+		t.Errorf("Expected no definitions for built-in types, got %v", spec.Definitions)
+	}
+
+	if s := simpleSchema("J", ""); s.Type != "integer" || s.Format != "int64" {
+		t.Errorf("Expected int64 param, got %+v", s)
+	}
+	if s := simpleSchema("Ljava/util/UUID;", ""); s.Type != "string" || s.Format != "uuid" {
+		t.Errorf("Expected uuid param, got %+v", s)
+	}
+	if s := simpleSchema("Ljava/math/BigInteger;", ""); s.Type != "integer" || s.Format != "" {
+		t.Errorf("Expected an integer param without format, got %+v", s)
+	}
+	for sig, want := range map[string]string{
+		// toString() is ISO 8601
+		"Ljava/time/Instant;":          "date-time",
+		"Ljava/time/OffsetDateTime;":   "date-time",
+		"Ljava/time/ZonedDateTime;":    "date-time",
+		"Ljava/time/LocalDateTime;":    "date-time",
+		"Ljava/time/LocalDate;":        "date",
+		"Lorg/threeten/bp/Instant;":    "date-time",
+		"Lkotlinx/datetime/LocalDate;": "date",
+		// toString() is not
+		"Ljava/util/Date;":     "",
+		"Ljava/sql/Date;":      "",
+		"Ljava/sql/Timestamp;": "",
+		"Ljava/sql/Time;":      "",
+	} {
+		if s := simpleSchema(sig, ""); s.Type != "string" || s.Format != want {
+			t.Errorf("%s: expected a string param with format %q, got %+v", sig, want, s)
+		}
+	}
+}
+
+const suspendApiSmali = `.class public interface abstract Lcom/example/SuspendApi;
+.super Ljava/lang/Object;
+
+# virtual methods
+.method public abstract getDevice(Ljava/lang/String;Lkotlin/coroutines/Continuation;)Ljava/lang/Object;
+    .param p1    # Ljava/lang/String;
+        .annotation runtime Lretrofit2/http/Path;
+            value = "id"
+        .end annotation
+    .end param
+    .param p2, "$completion"    # Lkotlin/coroutines/Continuation;
+    .annotation system Ldalvik/annotation/Signature;
+        value = {
+            "(",
+            "Ljava/lang/String;",
+            "Lkotlin/coroutines/Continuation<",
+            "-",
+            "Lretrofit2/Response<",
+            "Lcom/example/models/Device;",
+            ">;>;)",
+            "Ljava/lang/Object;"
+        }
+    .end annotation
+
+    .annotation runtime Lretrofit2/http/GET;
+        value = "devices/{id}"
+    .end annotation
+.end method
+
+.method public abstract deleteDevice(Ljava/lang/String;Lkotlin/coroutines/Continuation;)Ljava/lang/Object;
+    .param p1    # Ljava/lang/String;
+        .annotation runtime Lretrofit2/http/Path;
+            value = "id"
+        .end annotation
+    .end param
+    .annotation system Ldalvik/annotation/Signature;
+        value = {
+            "(",
+            "Ljava/lang/String;",
+            "Lkotlin/coroutines/Continuation<",
+            "-",
+            "Lkotlin/Unit;",
+            ">;)",
+            "Ljava/lang/Object;"
+        }
+    .end annotation
+
+    .annotation runtime Lretrofit2/http/DELETE;
+        value = "devices/{id}"
+    .end annotation
+.end method
+`
+
+func TestSuspendFunctions(t *testing.T) {
+	isolateGlobals(t)
+	classToFilePath["Lcom/example/models/Device;"] = "testdata/Device.smali"
+	apis, err := ExtractAPIEndpoints(suspendApiSmali)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(apis) != 2 {
+		t.Fatalf("Expected 2 endpoints, got %d", len(apis))
+	}
+	if len(apis[0].Params) != 1 || apis[0].Params[0].PathVar != "id" {
+		t.Errorf("Expected the continuation to be dropped, got %+v", apis[0].Params)
+	}
+	if apis[0].ReturnType != "Lretrofit2/Response;" {
+		t.Errorf("Expected the continuation's type as return type, got %s", apis[0].ReturnType)
+	}
+
+	spec, err := GenerateSwaggerSpec(apis)
+	if err != nil {
+		t.Fatal(err)
+	}
+	item := spec.Paths.Paths["/devices/{id}"]
+	if resp := item.Get.Responses.StatusCodeResponses[200]; resp.Schema == nil || resp.Schema.Ref.String() != "#/definitions/Device" {
+		t.Errorf("Expected a Device response, got %+v", resp.Schema)
+	}
+	if resp := item.Delete.Responses.StatusCodeResponses[200]; resp.Schema != nil {
+		t.Errorf("Expected no content for Unit, got %+v", resp.Schema)
+	}
+	if len(item.Get.Parameters) != 1 {
+		t.Errorf("Expected only the path param, got %+v", item.Get.Parameters)
+	}
+}
@@ -0,0 +1,116 @@
+package parser
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"strings"
+
+	swagger "github.com/go-openapi/spec"
+	"gopkg.in/yaml.v3"
+)
+
+// TypeMapping gives a fixed schema to the classes matching Match, instead of expanding them
+// into a definition. Useful for types written as scalars by custom TypeAdapters, e.g. Money.
+type TypeMapping struct {
+	// Match is a class as a descriptor (Lcom/example/Money;) or java name (com.example.Money),
+	// or a package glob: com.example.ids.* for the classes of a package, com.example.** for its subpackages too
+	Match   string `yaml:"match"`
+	Type    string `yaml:"type"`
+	Format  string `yaml:"format"`
+	Pattern string `yaml:"pattern"`
+	// Ref is a definition name or a full $ref, e.g. "#/definitions/Money". The definition must be in
+	// the spec, e.g. the one generated for an app class, references to missing ones are reported
+	// as diagnostics.
+	Ref         string `yaml:"ref"`
+	Description string `yaml:"description"`
+}
+
+// typeMappingFile is the layout of the config file, YAML or JSON
+type typeMappingFile struct {
+	Mappings []TypeMapping `yaml:"mappings"`
+}
+
+// User supplied mappings, consulted before any automatic class expansion
+var typeMappings []TypeMapping
+
+// LoadTypeMappings reads a YAML or JSON type mapping file, e.g.
+//
+//	mappings:
+//	  - match: com.example.Money
+//	    type: string
+//	    pattern: '^-?\d+\.\d{2}$'
+//	  - match: com.example.ids.*
+//	    type: string
+//	    format: uuid
+func LoadTypeMappings(filePath string) error {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return err
+	}
+	var file typeMappingFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return fmt.Errorf("parsing %s: %w", filePath, err)
+	}
+	for i, m := range file.Mappings {
+		if m.Match == "" {
+			return fmt.Errorf("%s: mapping %d has no match", filePath, i+1)
+		}
+		if m.Type == "" && m.Ref == "" {
+			return fmt.Errorf("%s: mapping for %s needs a type or a ref", filePath, m.Match)
+		}
+		if _, err := path.Match(binaryClassName(m.Match), ""); err != nil {
+			return fmt.Errorf("%s: bad pattern %s: %w", filePath, m.Match, err)
+		}
+	}
+	log.Printf("Loaded %d type mappings from %s", len(file.Mappings), filePath)
+	typeMappings = file.Mappings
+	return nil
+}
+
+// findTypeMapping returns the mapping of a binary class name, e.g. com/example/Money.
+// Exact matches win over globs, globs are tried in file order.
+func findTypeMapping(className string) (TypeMapping, bool) {
+	for _, m := range typeMappings {
+		if binaryClassName(m.Match) == className {
+			return m, true
+		}
+	}
+	for _, m := range typeMappings {
+		pattern := binaryClassName(m.Match)
+		if prefix, ok := strings.CutSuffix(pattern, "/**"); ok {
+			if strings.HasPrefix(className, prefix+"/") {
+				return m, true
+			}
+			continue
+		}
+		if ok, _ := path.Match(pattern, className); ok {
+			return m, true
+		}
+	}
+	return TypeMapping{}, false
+}
+
+// schema builds the fixed schema of the mapping
+func (m TypeMapping) schema() (*swagger.Schema, error) {
+	if m.Ref != "" {
+		ref := m.Ref
+		if !strings.HasPrefix(ref, "#/") {
+			ref = "#/definitions/" + ref
+		}
+		r, err := swagger.NewRef(ref)
+		if err != nil {
+			return nil, fmt.Errorf("error building ref: %w", err)
+		}
+		return &swagger.Schema{SchemaProps: swagger.SchemaProps{Ref: r}}, nil
+	}
+	return &swagger.Schema{
+		SchemaProps: swagger.SchemaProps{
+			Type:        []string{m.Type},
+			Format:      m.Format,
+			Pattern:     m.Pattern,
+			Description: m.Description,
+		},
+	}, nil
+}
@@ -0,0 +1,97 @@
+package parser
+
+import (
+	"strings"
+	"testing"
+
+	swagger "github.com/go-openapi/spec"
+)
+
+func TestTypeMappings(t *testing.T) {
+	isolateGlobals(t)
+	if err := LoadTypeMappings("testdata/types.yaml"); err != nil {
+		t.Fatal(err)
+	}
+	spec := &swagger.Swagger{SwaggerProps: swagger.SwaggerProps{Definitions: map[string]swagger.Schema{}}}
+
+	money, err := interpretTypeAndBuildDefinition("Lcom/example/models/Money;", spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !money.Type.Contains("string") || money.Pattern != `^-?\d+\.\d{2}$` {
+		t.Errorf("Expected Money to be a string with a pattern, got %+v", money)
+	}
+	// exact match beats the package glob
+	if id, _ := interpretTypeAndBuildDefinition("Lcom/example/ids/DeviceId;", spec); id.Format != "uuid" {
+		t.Errorf("Expected DeviceId to be a uuid, got %+v", id)
+	}
+	if id, _ := interpretTypeAndBuildDefinition("Lcom/example/ids/UserId;", spec); id.Format != "int64" {
+		t.Errorf("Expected UserId to be an int64, got %+v", id)
+	}
+	if _, ok := findTypeMapping("com/example/ids/nested/OtherId"); ok {
+		t.Errorf("Expected .* to stay within its package")
+	}
+	if point, _ := interpretTypeAndBuildDefinition("Lcom/example/geo/wgs84/Point;", spec); point.Ref.String() != "#/definitions/GeoPoint" {
+		t.Errorf("Expected Point to reference GeoPoint, got %+v", point)
+	}
+	if _, ok := spec.Definitions["Point"]; ok {
+		t.Errorf("Expected mapped types not to be expanded, got %v", spec.Definitions)
+	}
+
+	if s := simpleSchema("Lcom/example/ids/DeviceId;", ""); s.Type != "string" || s.Format != "uuid" {
+		t.Errorf("Expected a uuid param, got %+v", s)
+	}
+}
+
+const mappedTypesApiSmali = `.class public interface abstract Lcom/example/MappedApi;
+.super Ljava/lang/Object;
+
+# virtual methods
+.method public abstract device()Lcom/example/models/Device;
+    .annotation runtime Lretrofit2/http/GET;
+        value = "device"
+    .end annotation
+.end method
+
+.method public abstract legacyDevice()Lcom/example/models/LegacyDevice;
+    .annotation runtime Lretrofit2/http/GET;
+        value = "legacy-device"
+    .end annotation
+.end method
+
+.method public abstract point()Lcom/example/geo/Point;
+    .annotation runtime Lretrofit2/http/GET;
+        value = "point"
+    .end annotation
+.end method
+`
+
+func TestTypeMappingRefs(t *testing.T) {
+	isolateGlobals(t)
+	if err := LoadTypeMappings("testdata/types.yaml"); err != nil {
+		t.Fatal(err)
+	}
+	classToFilePath["Lcom/example/models/Device;"] = "testdata/Device.smali"
+	diagnostics = nil
+
+	apis, err := ExtractAPIEndpoints(mappedTypesApiSmali)
+	if err != nil {
+		t.Fatal(err)
+	}
+	spec, err := GenerateSwaggerSpec(apis)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	legacy := spec.Paths.Paths["/legacy-device"].Get.Responses.StatusCodeResponses[200].Schema
+	if legacy == nil || legacy.Ref.String() != "#/definitions/Device" {
+		t.Fatalf("Expected LegacyDevice to reference Device, got %+v", legacy)
+	}
+	if _, ok := spec.Definitions["Device"]; !ok {
+		t.Errorf("Expected the referenced Device definition to resolve, got %v", spec.Definitions)
+	}
+	// nothing generates GeoPoint
+	if got := Diagnostics(); len(got) != 1 || !strings.Contains(got[0], "GeoPoint") {
+		t.Errorf("Expected a diagnostic for the dangling GeoPoint reference only, got %v", got)
+	}
+}
@@ -0,0 +1,131 @@
+package parser
+
+import (
+	"strings"
+
+	swagger "github.com/go-openapi/spec"
+)
+
+// WrapperKind says how a generic library type is mapped onto its type arguments
+type WrapperKind int
+
+const (
+	UnwrapWrapper    WrapperKind = iota // Observable<T>, Call<T>, Deferred<T>, ... => T itself
+	ArrayWrapper                        // List<T>, Collection<T>, ... => array of T
+	SetWrapper                          // Set<T> => array of unique T
+	MapWrapper                          // Map<K, V> => object with V values
+	NoContentWrapper                    // Completable => no response body
+)
+
+// wrapperRegistry describes how to handle "wrapper" types like Observable<T>, List<T>, etc.
+// keyed by binary class name
+var wrapperRegistry = map[string]WrapperKind{
+	// retrofit
+	"retrofit2/Call":                   UnwrapWrapper,
+	"retrofit2/Response":               UnwrapWrapper,
+	"retrofit2/adapter/rxjava2/Result": UnwrapWrapper,
+	"retrofit2/adapter/rxjava3/Result": UnwrapWrapper,
+	// RxJava 3
+	"io/reactivex/rxjava3/core/Observable":  UnwrapWrapper,
+	"io/reactivex/rxjava3/core/Single":      UnwrapWrapper,
+	"io/reactivex/rxjava3/core/Maybe":       UnwrapWrapper,
+	"io/reactivex/rxjava3/core/Flowable":    UnwrapWrapper,
+	"io/reactivex/rxjava3/core/Completable": NoContentWrapper,
+	// RxJava 2
+	"io/reactivex/Observable":  UnwrapWrapper,
+	"io/reactivex/Single":      UnwrapWrapper,
+	"io/reactivex/Maybe":       UnwrapWrapper,
+	"io/reactivex/Flowable":    UnwrapWrapper,
+	"io/reactivex/Completable": NoContentWrapper,
+	// coroutines, architecture components and futures
+	"kotlinx/coroutines/flow/Flow":                       UnwrapWrapper,
+	"kotlinx/coroutines/Deferred":                        UnwrapWrapper,
+	"androidx/lifecycle/LiveData":                        UnwrapWrapper,
+	"com/google/common/util/concurrent/ListenableFuture": UnwrapWrapper,
+	"java/util/concurrent/CompletableFuture":             UnwrapWrapper,
+	"java/util/concurrent/CompletionStage":               UnwrapWrapper,
+	// collections
+	"java/lang/Iterable":      ArrayWrapper,
+	"java/util/Collection":    ArrayWrapper,
+	"java/util/List":          ArrayWrapper,
+	"java/util/ArrayList":     ArrayWrapper,
+	"java/util/LinkedList":    ArrayWrapper,
+	"java/util/Set":           SetWrapper,
+	"java/util/HashSet":       SetWrapper,
+	"java/util/LinkedHashSet": SetWrapper,
+	"java/util/SortedSet":     SetWrapper,
+	"java/util/TreeSet":       SetWrapper,
+	"java/util/Map":           MapWrapper,
+	"java/util/HashMap":       MapWrapper,
+	"java/util/LinkedHashMap": MapWrapper,
+	"java/util/SortedMap":     MapWrapper,
+	"java/util/TreeMap":       MapWrapper,
+}
+
+// RegisterWrapper adds or replaces the handling of a wrapper type, e.g. a company specific
+// `com.example.net.ApiResult<T>` with UnwrapWrapper. className may be a descriptor
+// (Lcom/example/net/ApiResult;), a binary (com/example/net/ApiResult) or a java name.
+func RegisterWrapper(className string, kind WrapperKind) {
+	wrapperRegistry[binaryClassName(className)] = kind
+}
+
+// binaryClassName normalizes a class name to its binary form, e.g. com/example/Money
+func binaryClassName(name string) string {
+	name = strings.TrimSpace(name)
+	if strings.HasPrefix(name, "L") && strings.HasSuffix(name, ";") {
+		return name[1 : len(name)-1]
+	}
+	return strings.ReplaceAll(name, ".", "/")
+}
+
+// wrapperSchema maps a wrapper type onto its type arguments, raw wrappers fall back to free-form content
+func wrapperSchema(kind WrapperKind, t *jvmType, spec *swagger.Swagger) (*swagger.Schema, error) {
+	switch kind {
+	case NoContentWrapper:
+		return nil, nil
+	case ArrayWrapper, SetWrapper:
+		items := primitiveSchema("string")
+		if len(t.Args) == 1 {
+			s, err := schemaForType(t.Args[0], spec)
+			if err != nil {
+				return nil, err
+			}
+			if s != nil {
+				items = s
+			}
+		}
+		return &swagger.Schema{
+			SchemaProps: swagger.SchemaProps{
+				Type:        []string{"array"},
+				Items:       &swagger.SchemaOrArray{Schema: items},
+				UniqueItems: kind == SetWrapper,
+			},
+		}, nil
+	case MapWrapper:
+		// Map<K,V> => interpret V, keys are always strings in JSON
+		values := primitiveSchema("object")
+		if len(t.Args) == 2 {
+			s, err := schemaForType(t.Args[1], spec)
+			if err != nil {
+				return nil, err
+			}
+			if s != nil {
+				values = s
+			}
+		}
+		return &swagger.Schema{
+			SchemaProps: swagger.SchemaProps{
+				Type: []string{"object"},
+				AdditionalProperties: &swagger.SchemaOrBool{
+					Allows: true,
+					Schema: values,
+				},
+			},
+		}, nil
+	default:
+		if len(t.Args) != 1 {
+			return primitiveSchema("object"), nil
+		}
+		return schemaForType(t.Args[0], spec)
+	}
+}
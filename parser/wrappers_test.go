@@ -0,0 +1,113 @@
+package parser
+
+import (
+	"testing"
+
+	swagger "github.com/go-openapi/spec"
+)
+
+func TestWrappers(t *testing.T) {
+	isolateGlobals(t)
+	classToFilePath["Lcom/example/models/Device;"] = "testdata/Device.smali"
+	spec := &swagger.Swagger{SwaggerProps: swagger.SwaggerProps{Definitions: map[string]swagger.Schema{}}}
+
+	for _, sig := range []string{
+		"Lio/reactivex/Single<Lcom/example/models/Device;>;",
+		"Lio/reactivex/rxjava3/core/Maybe<Lcom/example/models/Device;>;",
+		"Lkotlinx/coroutines/flow/Flow<Lcom/example/models/Device;>;",
+		"Lkotlinx/coroutines/Deferred<Lretrofit2/Response<Lcom/example/models/Device;>;>;",
+		"Landroidx/lifecycle/LiveData<Lcom/example/models/Device;>;",
+		"Lcom/google/common/util/concurrent/ListenableFuture<Lcom/example/models/Device;>;",
+	} {
+		schema, err := interpretTypeAndBuildDefinition(sig, spec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if schema == nil || schema.Ref.String() != "#/definitions/Device" {
+			t.Errorf("%s: expected a Device, got %+v", sig, schema)
+		}
+	}
+
+	for _, sig := range []string{"Lio/reactivex/Completable;", "Lio/reactivex/rxjava3/core/Completable;"} {
+		if schema, _ := interpretTypeAndBuildDefinition(sig, spec); schema != nil {
+			t.Errorf("%s: expected no content, got %+v", sig, schema)
+		}
+	}
+
+	set, _ := interpretTypeAndBuildDefinition("Ljava/util/Set<Ljava/lang/String;>;", spec)
+	if !set.Type.Contains("array") || !set.UniqueItems {
+		t.Errorf("Expected an array of unique items, got %+v", set)
+	}
+	iterable, _ := interpretTypeAndBuildDefinition("Ljava/lang/Iterable<+Lcom/example/models/Device;>;", spec)
+	if !iterable.Type.Contains("array") || iterable.Items.Schema.Ref.String() != "#/definitions/Device" {
+		t.Errorf("Expected an array of Device, got %+v", iterable)
+	}
+	linked, _ := interpretTypeAndBuildDefinition("Ljava/util/LinkedHashMap<Ljava/lang/String;Ljava/lang/Long;>;", spec)
+	if !linked.AdditionalProperties.Schema.Type.Contains("integer") {
+		t.Errorf("Expected a map of integers, got %+v", linked)
+	}
+}
+
+func TestRegisterWrapper(t *testing.T) {
+	isolateGlobals(t)
+	classToFilePath["Lcom/example/models/Device;"] = "testdata/Device.smali"
+	RegisterWrapper("com.example.net.ApiResult", UnwrapWrapper)
+	spec := &swagger.Swagger{SwaggerProps: swagger.SwaggerProps{Definitions: map[string]swagger.Schema{}}}
+
+	schema, err := interpretTypeAndBuildDefinition("Lcom/example/net/ApiResult<Lcom/example/models/Device;>;", spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if schema.Ref.String() != "#/definitions/Device" {
+		t.Errorf("Expected the registered wrapper to be unwrapped, got %+v", schema)
+	}
+	if _, ok := spec.Definitions["ApiResult_Device"]; ok {
+		t.Errorf("Expected no definition for the wrapper itself")
+	}
+}
+
+const rawWrappersApiSmali = `.class public interface abstract Lcom/example/RawApi;
+.super Ljava/lang/Object;
+
+# virtual methods
+.method public abstract ping()Lio/reactivex/Completable;
+    .annotation runtime Lretrofit2/http/POST;
+        value = "ping"
+    .end annotation
+.end method
+
+.method public abstract raw()Lretrofit2/Call;
+    .annotation runtime Lretrofit2/http/GET;
+        value = "raw"
+    .end annotation
+.end method
+
+.method public abstract device()Lcom/example/models/Device;
+    .annotation runtime Lretrofit2/http/GET;
+        value = "device"
+    .end annotation
+.end method
+`
+
+func TestNonGenericReturnTypes(t *testing.T) {
+	isolateGlobals(t)
+	classToFilePath["Lcom/example/models/Device;"] = "testdata/Device.smali"
+	apis, err := ExtractAPIEndpoints(rawWrappersApiSmali)
+	if err != nil {
+		t.Fatal(err)
+	}
+	spec, err := GenerateSwaggerSpec(apis)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp := spec.Paths.Paths["/ping"].Post.Responses.StatusCodeResponses[200]; resp.Schema != nil {
+		t.Errorf("Expected Completable to have no content, got %+v", resp.Schema)
+	}
+	if resp := spec.Paths.Paths["/raw"].Get.Responses.StatusCodeResponses[200]; resp.Schema == nil || !resp.Schema.Type.Contains("object") {
+		t.Errorf("Expected a raw Call to have free-form content, got %+v", resp.Schema)
+	}
+	if resp := spec.Paths.Paths["/device"].Get.Responses.StatusCodeResponses[200]; resp.Schema == nil || resp.Schema.Ref.String() != "#/definitions/Device" {
+		t.Errorf("Expected a Device, got %+v", resp.Schema)
+	}
+}